@@ -0,0 +1,148 @@
+// adaptiveRefinement
+package main
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// The color variance is measured on a downscaled raster with at most this many pixels per side.
+const g_adaptiveAnalysisSize = 400
+
+// Mean color and color deviation of the pixels in one cell.
+type cellColorStats struct {
+	sum, sumSquared [3]float64
+	count           int
+}
+
+func (s *cellColorStats) add(c mgl32.Vec3) {
+	for i := 0; i < 3; i++ {
+		s.sum[i] += float64(c[i])
+		s.sumSquared[i] += float64(c[i]) * float64(c[i])
+	}
+	s.count++
+}
+
+func (s cellColorStats) mean() [3]float64 {
+	if s.count == 0 {
+		return [3]float64{}
+	}
+	return [3]float64{s.sum[0] / float64(s.count), s.sum[1] / float64(s.count), s.sum[2] / float64(s.count)}
+}
+
+// Color variance in [0,1], averaged over the three channels.
+func (s cellColorStats) variance() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	mean := s.mean()
+	variance := 0.0
+	for c := 0; c < 3; c++ {
+		variance += math.Max(0, s.sumSquared[c]/float64(s.count)-mean[c]*mean[c])
+	}
+	return variance / 3.0
+}
+
+// Standard deviation of the cell color in [0,1].
+func (s cellColorStats) deviation() float64 {
+	return math.Sqrt(s.variance())
+}
+
+// Rasterizes the (Euclidean) Voronoi cells of points and collects the image colors per cell.
+func measureCellColors(points []sc.Vector, img *image.RGBA, rangeX, rangeY float64) []cellColorStats {
+	scale := math.Min(1, g_adaptiveAnalysisSize/math.Max(rangeX, rangeY))
+	width := int(math.Max(1, rangeX*scale))
+	height := int(math.Max(1, rangeY*scale))
+
+	grid := CreateSiteGrid(points, rangeX, rangeY, calcExpectedRadius(len(points), rangeX, rangeY, 0))
+	labels := CreateMetricVoronoiLabels(grid, METRIC_EUCLIDEAN, 2, width, height, rangeX, rangeY)
+
+	stats := make([]cellColorStats, len(points))
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			l := labels[py*width+px]
+			if l < 0 {
+				continue
+			}
+			stats[grid.Index[l]].add(sampleImage(img, (float32(px)+0.5)/float32(width), (float32(py)+0.5)/float32(height)))
+		}
+	}
+	return stats
+}
+
+// Quadtree like refinement: Every cell whose color deviation is above threshold is replaced by four
+// points at half its size, until maxDepth levels are done, nothing needs refinement or budget is reached.
+// The cells with the largest deviation are refined first, so a small budget still goes to the right places.
+// progress is called after every level with the level and the current point count.
+func CreateAdaptivePoints(start []sc.Vector, img *image.RGBA, threshold float64, maxDepth, budget int, rangeX, rangeY, margin float64, progress func(level, pointCount int)) []sc.Vector {
+	points := append([]sc.Vector{}, start...)
+	sizes := make([]float64, len(points))
+	startSize := calcExpectedRadius(len(start), rangeX, rangeY, margin)
+	for i := range sizes {
+		sizes[i] = startSize
+	}
+
+	// Cells smaller than two analysis pixels cannot be measured anymore.
+	minSize := 2.0 * math.Max(rangeX, rangeY) / g_adaptiveAnalysisSize
+
+	for level := 1; level <= maxDepth && len(points) < budget; level++ {
+		stats := measureCellColors(points, img, rangeX, rangeY)
+
+		var candidates []int
+		for i, s := range stats {
+			if s.deviation() > threshold && sizes[i]/2.0 >= minSize {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.Slice(candidates, func(a, b int) bool {
+			return stats[candidates[a]].deviation() > stats[candidates[b]].deviation()
+		})
+
+		refine := make([]bool, len(points))
+		count := len(points)
+		// Every refinement replaces one point by four.
+		for _, i := range candidates {
+			if count+3 > budget {
+				break
+			}
+			refine[i] = true
+			count += 3
+		}
+		if count == len(points) {
+			break
+		}
+
+		var newPoints []sc.Vector
+		var newSizes []float64
+		for i, p := range points {
+			if !refine[i] {
+				newPoints = append(newPoints, p)
+				newSizes = append(newSizes, sizes[i])
+				continue
+			}
+			offset := sizes[i] / 4.0
+			for _, d := range [...]sc.Vector{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+				child := sc.Vector{p.X + d.X*offset, p.Y + d.Y*offset}
+				child.X = math.Max(margin, math.Min(rangeX-margin, child.X))
+				child.Y = math.Max(margin, math.Min(rangeY-margin, child.Y))
+				newPoints = append(newPoints, child)
+				newSizes = append(newSizes, sizes[i]/2.0)
+			}
+		}
+		points = newPoints
+		sizes = newSizes
+
+		if progress != nil {
+			progress(level, len(points))
+		}
+	}
+
+	return points
+}
@@ -0,0 +1,62 @@
+// alphaShape
+package main
+
+import (
+	"math"
+
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+// The alpha shape (concave hull) of the point set. Triangles is the filled area,
+// Boundary are all edges between a kept triangle and a removed one (or the outside).
+type AlphaShape struct {
+	Triangles [][3]sc.Vector
+	Boundary  []sc.SimpleEdge
+}
+
+func circumradius(a, b, c sc.Vector) float64 {
+	area := math.Abs(sc.SideOfLine(a, b, c)) / 2.0
+	if area <= sc.EPS {
+		return math.MaxFloat64
+	}
+	return sc.Length(sc.Sub(a, b)) * sc.Length(sc.Sub(b, c)) * sc.Length(sc.Sub(c, a)) / (4.0 * area)
+}
+
+// Keeps all Delaunay triangles with a circumradius of at most alphaRadius. Large radii mean the triangle
+// spans over an empty region of the point cloud. An infinite radius results in the convex hull.
+func CreateAlphaShape(d sc.Delaunay, alphaRadius float64) AlphaShape {
+	shape := AlphaShape{}
+
+	kept := make([]bool, len(d.Faces))
+	for i, f := range d.Faces {
+		e1 := f.EEdge
+		e2 := d.Edges[e1].ENext
+		e3 := d.Edges[e2].ENext
+		v1 := d.Vertices[d.Edges[e1].VOrigin].Pos
+		v2 := d.Vertices[d.Edges[e2].VOrigin].Pos
+		v3 := d.Vertices[d.Edges[e3].VOrigin].Pos
+
+		if circumradius(v1, v2, v3) <= alphaRadius {
+			kept[i] = true
+			shape.Triangles = append(shape.Triangles, [3]sc.Vector{v1, v2, v3})
+		}
+	}
+
+	for i, f := range d.Faces {
+		if !kept[i] {
+			continue
+		}
+		e := f.EEdge
+		for j := 0; j < 3; j++ {
+			twinFace := d.Edges[d.Edges[e].ETwin].FFace
+			if twinFace == sc.EmptyFace || !kept[twinFace] {
+				v1 := d.Vertices[d.Edges[e].VOrigin].Pos
+				v2 := d.Vertices[d.Edges[d.Edges[e].ETwin].VOrigin].Pos
+				shape.Boundary = append(shape.Boundary, sc.SimpleEdge{v1, v2})
+			}
+			e = d.Edges[e].ENext
+		}
+	}
+
+	return shape
+}
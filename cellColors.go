@@ -0,0 +1,550 @@
+// cellColors
+package main
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// Colors are sampled in the shader (six samples around the vertex). Fast, but ignores the real cell shape.
+	CELL_COLOR_PREVIEW = iota
+	// Average of every image pixel inside the cell.
+	CELL_COLOR_MEAN = iota
+	// Per channel median of the pixels inside the cell.
+	CELL_COLOR_MEDIAN = iota
+	// The most frequent color after quantization. Keeps cells crisp that cover two objects.
+	CELL_COLOR_MOST_FREQUENT = iota
+	// Center of the largest k-means cluster of the pixels inside the cell.
+	CELL_COLOR_DOMINANT = iota
+)
+
+const (
+	// One color per face.
+	SHADING_FLAT = iota
+	// Delaunay triangles get one color per vertex and are interpolated. Cells stay flat.
+	SHADING_GOURAUD = iota
+	// Every face gets the linear gradient that fits the image inside it best.
+	SHADING_GRADIENT = iota
+)
+
+// Color as a linear function of the window position. Flat colors have no slope.
+type ColorGradient struct {
+	Center sc.Vector
+	// Color at Center.
+	Color  mgl32.Vec4
+	DX, DY mgl32.Vec3
+}
+
+// Color at p, clamped to [0,1].
+func (g ColorGradient) At(p sc.Vector) mgl32.Vec4 {
+	c := g.Color
+	for i := 0; i < 3; i++ {
+		v := float64(c[i]) + float64(g.DX[i])*(p.X-g.Center.X) + float64(g.DY[i])*(p.Y-g.Center.Y)
+		c[i] = float32(math.Max(0, math.Min(1, v)))
+	}
+	return c
+}
+
+func flatGradients(colors []mgl32.Vec4) []ColorGradient {
+	if colors == nil {
+		return nil
+	}
+	gradients := make([]ColorGradient, len(colors))
+	for i, c := range colors {
+		gradients[i] = ColorGradient{Color: c}
+	}
+	return gradients
+}
+
+// The linear gradient that has exactly the given colors at the triangle corners.
+// Degenerated triangles get their mean color.
+func gradientThroughCorners(t [3]sc.Vector, colors [3]mgl32.Vec4) ColorGradient {
+	g := ColorGradient{Center: t[0], Color: colors[0]}
+
+	e1 := sc.Sub(t[1], t[0])
+	e2 := sc.Sub(t[2], t[0])
+	det := e1.X*e2.Y - e1.Y*e2.X
+	if math.Abs(det) <= sc.EPS {
+		g.Color = colors[0].Add(colors[1]).Add(colors[2]).Mul(1.0 / 3.0)
+		return g
+	}
+
+	for i := 0; i < 3; i++ {
+		d1 := float64(colors[1][i] - colors[0][i])
+		d2 := float64(colors[2][i] - colors[0][i])
+		g.DX[i] = float32((d1*e2.Y - d2*e1.Y) / det)
+		g.DY[i] = float32((d2*e1.X - d1*e2.X) / det)
+	}
+	// Alpha has no gradient.
+	g.Color[3] = (colors[0][3] + colors[1][3] + colors[2][3]) / 3
+	return g
+}
+
+// Least squares fit of a linear color gradient to all image pixels inside every polygon.
+// The gradient is anchored at the pixel center of mass, so its color there is the mean color, averaged in space.
+// The slopes are always fitted to the sRGB values, because that is what the GPU interpolates between the vertices.
+// Polygons that are too small or thin for a slope get a flat color like in computePolygonColors.
+// Fully transparent pixels have no color and only count for the mean alpha.
+func computePolygonGradients(polygons [][]sc.Vector, img *image.RGBA, space int, rangeX, rangeY float64) []ColorGradient {
+	gradients := make([]ColorGradient, len(polygons))
+
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+	pixelX := rangeX / float64(width)
+	pixelY := rangeY / float64(height)
+
+	for i, poly := range polygons {
+		poly = clipPolygonToRect(poly, 0, 0, rangeX, rangeY)
+		if len(poly) < 3 {
+			continue
+		}
+
+		var colors cellColorStats
+		var spaceSum mgl32.Vec3
+		var sumX, sumY, sumXX, sumXY, sumYY float64
+		var sumXC, sumYC [3]float64
+		var alpha float64
+		pixels := 0
+		rasterizeConvexPolygon(poly, width, height, rangeX, rangeY, func(x, y int) {
+			pixels++
+			ca := imagePixelAlpha(img, x, y)
+			if ca.W() <= 0 {
+				return
+			}
+			alpha += float64(ca.W())
+			c := ca.Vec3()
+			// Pixel center in window coordinates.
+			px := (float64(x) + 0.5) * pixelX
+			py := rangeY - (float64(y)+0.5)*pixelY
+
+			colors.add(c)
+			spaceSum = spaceSum.Add(toColorSpace(c, space))
+			sumX += px
+			sumY += py
+			sumXX += px * px
+			sumXY += px * py
+			sumYY += py * py
+			for k := 0; k < 3; k++ {
+				sumXC[k] += px * float64(c[k])
+				sumYC[k] += py * float64(c[k])
+			}
+		})
+
+		if colors.count == 0 {
+			c := polygonCentroid(poly)
+			if pixels == 0 {
+				gradients[i] = ColorGradient{Color: sampleImageAlpha(img, float32(c.X/rangeX), float32(1.0-c.Y/rangeY))}
+			}
+			continue
+		}
+
+		n := float64(colors.count)
+		mean := colors.mean()
+		center := sc.Vector{sumX / n, sumY / n}
+		anchor := fromColorSpace(spaceSum.Mul(float32(1/n)), space)
+		gradients[i] = ColorGradient{Center: center, Color: anchor.Vec4(float32(alpha / float64(pixels)))}
+
+		// Centered second moments.
+		xx := sumXX/n - center.X*center.X
+		xy := sumXY/n - center.X*center.Y
+		yy := sumYY/n - center.Y*center.Y
+		det := xx*yy - xy*xy
+		// Less than about a pixel of extent in some direction.
+		if det <= (pixelX*pixelY)*(pixelX*pixelY) {
+			continue
+		}
+
+		for k := 0; k < 3; k++ {
+			xc := sumXC[k]/n - center.X*mean[k]
+			yc := sumYC[k]/n - center.Y*mean[k]
+			gradients[i].DX[k] = float32((xc*yy - yc*xy) / det)
+			gradients[i].DY[k] = float32((yc*xx - xc*xy) / det)
+		}
+	}
+
+	return gradients
+}
+
+// Gouraud colors for the Delaunay triangles: every vertex samples the image at its own position,
+// averaged over a few pixels in the color space.
+func gouraudTriangleColors(triangles [][3]sc.Vector, img *image.RGBA, space int, rangeX, rangeY float64) []ColorGradient {
+	// Most vertices belong to six triangles.
+	vertexColors := make(map[sc.Vector]mgl32.Vec4)
+
+	gradients := make([]ColorGradient, len(triangles))
+	for i, t := range triangles {
+		var colors [3]mgl32.Vec4
+		for k, p := range t {
+			color, ok := vertexColors[p]
+			if !ok {
+				color = sampleImageBox(img, float32(p.X/rangeX), float32(1.0-p.Y/rangeY), space)
+				vertexColors[p] = color
+			}
+			colors[k] = color
+		}
+		gradients[i] = gradientThroughCorners(t, colors)
+	}
+	return gradients
+}
+
+// Bits per channel for CELL_COLOR_MOST_FREQUENT.
+const g_colorQuantizationBits = 4
+
+// Number of clusters and iterations for CELL_COLOR_DOMINANT.
+const g_dominantColorClusters = 3
+const g_dominantColorIterations = 8
+
+// Larger cells are subsampled for the k-means, there is no visible difference.
+const g_dominantColorMaxPixels = 4096
+
+// Calculates one color per polygon from all full resolution image pixels inside it, averaged in the color space.
+// Polygons are in window coordinates and are clipped to the window first.
+// Polygons too small to contain a single pixel center get the color at their centroid.
+// The alpha of a polygon is the mean alpha of its pixels, the color ignores fully transparent pixels.
+func computePolygonColors(polygons [][]sc.Vector, img *image.RGBA, method, space int, rangeX, rangeY float64) []mgl32.Vec4 {
+	colors := make([]mgl32.Vec4, len(polygons))
+
+	width := img.Rect.Dx()
+	height := img.Rect.Dy()
+
+	for i, poly := range polygons {
+		poly = clipPolygonToRect(poly, 0, 0, rangeX, rangeY)
+		if len(poly) < 3 {
+			continue
+		}
+
+		var alpha float32
+		count := 0
+		if method == CELL_COLOR_MEAN {
+			// No need to keep all pixels around. Weighted by alpha, like averaging premultiplied colors.
+			var sum mgl32.Vec3
+			rasterizeConvexPolygon(poly, width, height, rangeX, rangeY, func(x, y int) {
+				c := imagePixelAlpha(img, x, y)
+				sum = sum.Add(toColorSpace(c.Vec3(), space).Mul(c.W()))
+				alpha += c.W()
+				count++
+			})
+			if count > 0 {
+				if alpha > 0 {
+					colors[i] = fromColorSpace(sum.Mul(1/alpha), space).Vec4(alpha / float32(count))
+				}
+				continue
+			}
+		} else {
+			var pixels []mgl32.Vec3
+			rasterizeConvexPolygon(poly, width, height, rangeX, rangeY, func(x, y int) {
+				c := imagePixelAlpha(img, x, y)
+				if c.W() > 0 {
+					pixels = append(pixels, c.Vec3())
+				}
+				alpha += c.W()
+				count++
+			})
+			if len(pixels) > 0 {
+				colors[i] = reducePixelColors(pixels, method, space).Vec4(alpha / float32(count))
+			}
+			if count > 0 {
+				continue
+			}
+		}
+
+		c := polygonCentroid(poly)
+		colors[i] = sampleImageAlpha(img, float32(c.X/rangeX), float32(1.0-c.Y/rangeY))
+	}
+
+	return colors
+}
+
+// One representative color for all pixels, pixels must not be empty.
+// Pixels are sRGB, the result is calculated in space and converted back.
+func reducePixelColors(pixels []mgl32.Vec3, method, space int) mgl32.Vec3 {
+	converted := func() []mgl32.Vec3 {
+		c := make([]mgl32.Vec3, len(pixels))
+		for i, p := range pixels {
+			c[i] = toColorSpace(p, space)
+		}
+		return c
+	}
+
+	switch method {
+	case CELL_COLOR_MEDIAN:
+		return fromColorSpace(medianColor(converted()), space)
+	case CELL_COLOR_MOST_FREQUENT:
+		return mostFrequentColor(pixels, g_colorQuantizationBits, space)
+	case CELL_COLOR_DOMINANT:
+		if len(pixels) > g_dominantColorMaxPixels {
+			step := len(pixels) / g_dominantColorMaxPixels
+			sampled := make([]mgl32.Vec3, 0, g_dominantColorMaxPixels+1)
+			for i := 0; i < len(pixels); i += step {
+				sampled = append(sampled, pixels[i])
+			}
+			pixels = sampled
+		}
+		centers, sizes := kMeansColors(converted(), g_dominantColorClusters, g_dominantColorIterations)
+		largest := 0
+		for i := range sizes {
+			if sizes[i] > sizes[largest] {
+				largest = i
+			}
+		}
+		return fromColorSpace(centers[largest], space)
+	}
+
+	return averageColors(pixels, space)
+}
+
+func medianColor(pixels []mgl32.Vec3) mgl32.Vec3 {
+	channel := make([]float32, len(pixels))
+	median := mgl32.Vec3{}
+	for c := 0; c < 3; c++ {
+		for i, p := range pixels {
+			channel[i] = p[c]
+		}
+		sort.Slice(channel, func(a, b int) bool { return channel[a] < channel[b] })
+		median[c] = channel[len(channel)/2]
+	}
+	return median
+}
+
+// Quantizes every (sRGB) pixel to bits per channel and returns the mean of the pixels in the fullest bin
+// (so the result is a real image color and not the center of the bin).
+func mostFrequentColor(pixels []mgl32.Vec3, bits uint, space int) mgl32.Vec3 {
+	levels := float32(int(1) << bits)
+	bin := func(p mgl32.Vec3) int {
+		key := 0
+		for c := 0; c < 3; c++ {
+			q := int(p[c] * levels)
+			if q >= int(levels) {
+				q = int(levels) - 1
+			}
+			key = key<<bits | q
+		}
+		return key
+	}
+
+	counts := make(map[int]int)
+	best := bin(pixels[0])
+	for _, p := range pixels {
+		b := bin(p)
+		counts[b]++
+		if counts[b] > counts[best] {
+			best = b
+		}
+	}
+
+	binPixels := make([]mgl32.Vec3, 0, counts[best])
+	for _, p := range pixels {
+		if bin(p) == best {
+			binPixels = append(binPixels, p)
+		}
+	}
+	return averageColors(binPixels, space)
+}
+
+func colorDistanceSquared(a, b mgl32.Vec3) float32 {
+	d := a.Sub(b)
+	return d.Dot(d)
+}
+
+// Plain Lloyd iterations. The first center is the mean color, every further one is the pixel farthest
+// away from all centers so far, so the result is deterministic. Returns the centers and the number of pixels of each cluster.
+func kMeansColors(pixels []mgl32.Vec3, k, iterations int) ([]mgl32.Vec3, []int) {
+	if k > len(pixels) {
+		k = len(pixels)
+	}
+
+	centers := make([]mgl32.Vec3, 0, k)
+	mean := mgl32.Vec3{}
+	for _, p := range pixels {
+		mean = mean.Add(p)
+	}
+	centers = append(centers, mean.Mul(1.0/float32(len(pixels))))
+
+	distances := make([]float32, len(pixels))
+	for i, p := range pixels {
+		distances[i] = colorDistanceSquared(p, centers[0])
+	}
+	for len(centers) < k {
+		farthest := 0
+		for i := range pixels {
+			if distances[i] > distances[farthest] {
+				farthest = i
+			}
+		}
+		centers = append(centers, pixels[farthest])
+		for i, p := range pixels {
+			if d := colorDistanceSquared(p, pixels[farthest]); d < distances[i] {
+				distances[i] = d
+			}
+		}
+	}
+
+	sizes := make([]int, k)
+	assignment := make([]int, len(pixels))
+	for it := 0; it < iterations; it++ {
+		for i, p := range pixels {
+			best := 0
+			for c := 1; c < k; c++ {
+				if colorDistanceSquared(p, centers[c]) < colorDistanceSquared(p, centers[best]) {
+					best = c
+				}
+			}
+			assignment[i] = best
+		}
+
+		sums := make([]mgl32.Vec3, k)
+		for c := range sizes {
+			sizes[c] = 0
+		}
+		for i, p := range pixels {
+			sums[assignment[i]] = sums[assignment[i]].Add(p)
+			sizes[assignment[i]]++
+		}
+		for c := range centers {
+			// Empty clusters keep their old center.
+			if sizes[c] > 0 {
+				centers[c] = sums[c].Mul(1.0 / float32(sizes[c]))
+			}
+		}
+	}
+
+	return centers, sizes
+}
+
+func cellPolygons(cells []VoronoiCell) [][]sc.Vector {
+	polygons := make([][]sc.Vector, len(cells))
+	for i, c := range cells {
+		polygons[i] = c.Polygon
+	}
+	return polygons
+}
+
+func trianglePolygons(triangles [][3]sc.Vector) [][]sc.Vector {
+	polygons := make([][]sc.Vector, len(triangles))
+	for i := range triangles {
+		polygons[i] = triangles[i][:]
+	}
+	return polygons
+}
+
+// Everything the exact colors depend on. The cells and triangles follow from the points.
+// Finished colors are reused as long as this stays the same.
+type exactColorInput struct {
+	points         []sc.Vector
+	img            *image.RGBA
+	method         int
+	shading        int
+	space          int
+	quantize       int
+	quantizeCount  int
+	palette        []mgl32.Vec4
+	adjustment     ColorAdjustment
+	rangeX, rangeY float64
+}
+
+func (a exactColorInput) equals(b exactColorInput) bool {
+	if a.img != b.img || a.method != b.method || a.shading != b.shading || a.space != b.space ||
+		a.quantize != b.quantize || a.quantizeCount != b.quantizeCount || a.adjustment != b.adjustment ||
+		a.rangeX != b.rangeX || a.rangeY != b.rangeY {
+		return false
+	}
+	if len(a.points) != len(b.points) || len(a.palette) != len(b.palette) {
+		return false
+	}
+	for i := range a.points {
+		if a.points[i] != b.points[i] {
+			return false
+		}
+	}
+	for i := range a.palette {
+		if a.palette[i] != b.palette[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Exact colors of all cells and triangles and the palette they were quantized to (nil without quantization).
+type exactColors struct {
+	cells     []ColorGradient
+	triangles []ColorGradient
+	palette   []mgl32.Vec4
+}
+
+// Exact colors calculated in the background. Closing cancel stops it.
+type exactColorJob struct {
+	input  exactColorInput
+	cancel chan struct{}
+}
+
+func canceled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// Calculates the exact colors for the cells and triangles from the full resolution image.
+// The color of a cell does not depend on its shape. Rounded cells and tiles are colored like the full cell.
+// Returns false, if cancel was closed before the colors were done.
+func computeExactColors(input exactColorInput, cells []VoronoiCell, triangles [][3]sc.Vector, cancel <-chan struct{}) (exactColors, bool) {
+	var result exactColors
+
+	if input.shading == SHADING_GRADIENT {
+		result.cells = computePolygonGradients(cellPolygons(cells), input.img, input.space, input.rangeX, input.rangeY)
+		if canceled(cancel) {
+			return result, false
+		}
+		result.triangles = computePolygonGradients(trianglePolygons(triangles), input.img, input.space, input.rangeX, input.rangeY)
+		return result, !canceled(cancel)
+	}
+
+	// Quantization needs real colors, the shader preview can not be reduced.
+	method := input.method
+	if method == CELL_COLOR_PREVIEW {
+		method = CELL_COLOR_MEAN
+	}
+	flatCellColors := computePolygonColors(cellPolygons(cells), input.img, method, input.space, input.rangeX, input.rangeY)
+	if canceled(cancel) {
+		return result, false
+	}
+	flatTriangleColors := computePolygonColors(trianglePolygons(triangles), input.img, method, input.space, input.rangeX, input.rangeY)
+	if canceled(cancel) {
+		return result, false
+	}
+
+	if input.quantize != QUANTIZE_NONE {
+		// The palette must contain the adjusted colors, so they are adjusted here instead of in the shader.
+		if input.adjustment.Active() {
+			cellUVs := make([]mgl32.Vec2, len(cells))
+			for i, c := range cells {
+				cellUVs[i] = positionUV(c.Site, input.rangeX, input.rangeY)
+			}
+			triangleUVs := make([]mgl32.Vec2, len(triangles))
+			for i, t := range triangles {
+				triangleUVs[i] = triangleCenterUV(t, input.rangeX, input.rangeY)
+			}
+			flatCellColors = adjustColors(flatCellColors, cellUVs, input.adjustment)
+			flatTriangleColors = adjustColors(flatTriangleColors, triangleUVs, input.adjustment)
+		}
+
+		// One palette for cells and triangles, so switching the faces never changes the inks.
+		result.palette = CreateQuantizedPalette(append(append([]mgl32.Vec4{}, flatCellColors...), flatTriangleColors...), input.quantize, input.quantizeCount, input.space, input.palette)
+		flatCellColors = remapToPalette(flatCellColors, result.palette, input.space)
+		flatTriangleColors = remapToPalette(flatTriangleColors, result.palette, input.space)
+	}
+
+	result.cells = flatGradients(flatCellColors)
+	result.triangles = flatGradients(flatTriangleColors)
+	if input.shading == SHADING_GOURAUD {
+		result.triangles = gouraudTriangleColors(triangles, input.img, input.space, input.rangeX, input.rangeY)
+	}
+	return result, !canceled(cancel)
+}
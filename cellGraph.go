@@ -0,0 +1,273 @@
+// cellGraph
+package main
+
+import (
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+const (
+	GRAPH_COLORING_GREEDY = iota
+	GRAPH_COLORING_FOUR   = iota
+)
+
+// Maximum number of repair steps for the four color attempt.
+const g_graphColoringMaxSteps = 500000
+
+// Neighbor graph of the Voronoi cells. CellGraph[i] lists all cells that share an edge with cell i.
+// Cell i is the Voronoi face i, which again is the Delaunay vertex i.
+type CellGraph [][]int
+
+// Two Voronoi cells are neighbors, exactly if their sites are connected by a Delaunay edge.
+func CreateCellAdjacencyGraph(d sc.Delaunay) CellGraph {
+	graph := make(CellGraph, len(d.Vertices))
+
+	for i, e := range d.Edges {
+		// Every edge exists twice. Only take the one with the smaller index.
+		if int(e.ETwin) <= i {
+			continue
+		}
+		v1 := e.VOrigin
+		v2 := d.Edges[e.ETwin].VOrigin
+		if !v1.Valid() || !v2.Valid() || v1 == v2 {
+			continue
+		}
+		graph[v1] = append(graph[v1], int(v2))
+		graph[v2] = append(graph[v2], int(v1))
+	}
+
+	return graph
+}
+
+// Returns true, if no two neighbors share the same color.
+func (g CellGraph) IsProperColoring(colors []int) bool {
+	for v, neighbors := range g {
+		for _, n := range neighbors {
+			if colors[v] == colors[n] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Picks a color for v out of colorCount colors. Colors used by neighbors are avoided.
+// If balanced, the least used of the allowed colors is taken, otherwise the smallest one.
+// If every color is taken by a neighbor, the one with the fewest conflicts is used.
+func pickColor(g CellGraph, v int, colors []int, usage []int, colorCount int, balanced bool) int {
+	conflicts := make([]int, colorCount)
+	for _, n := range g[v] {
+		if colors[n] >= 0 {
+			conflicts[colors[n]]++
+		}
+	}
+
+	best := 0
+	for c := 1; c < colorCount; c++ {
+		switch {
+		case conflicts[c] < conflicts[best]:
+			best = c
+		case conflicts[c] == conflicts[best] && balanced && usage[c] < usage[best]:
+			best = c
+		}
+	}
+	return best
+}
+
+// Smallest-last ordering: Repeatedly removes the vertex with the smallest degree and returns them in reverse.
+// Greedy coloring in this order needs at most 6 colors for planar graphs like ours.
+func smallestLastOrder(g CellGraph) []int {
+	degree := make([]int, len(g))
+	maxDegree := 0
+	for v, neighbors := range g {
+		degree[v] = len(neighbors)
+		if degree[v] > maxDegree {
+			maxDegree = degree[v]
+		}
+	}
+
+	// Bucket queue of vertices per current degree.
+	buckets := make([][]int, maxDegree+1)
+	for v := range g {
+		buckets[degree[v]] = append(buckets[degree[v]], v)
+	}
+
+	removed := make([]bool, len(g))
+	order := make([]int, len(g))
+	for i := len(g) - 1; i >= 0; i-- {
+		v := -1
+		for d := 0; d <= maxDegree && v == -1; d++ {
+			for len(buckets[d]) > 0 {
+				candidate := buckets[d][len(buckets[d])-1]
+				buckets[d] = buckets[d][:len(buckets[d])-1]
+				// Old bucket entries of vertices whose degree changed are just skipped.
+				if !removed[candidate] && degree[candidate] == d {
+					v = candidate
+					break
+				}
+			}
+		}
+
+		removed[v] = true
+		order[i] = v
+		for _, n := range g[v] {
+			if !removed[n] {
+				degree[n]--
+				buckets[degree[n]] = append(buckets[degree[n]], n)
+			}
+		}
+	}
+	return order
+}
+
+// Greedy coloring in smallest-last order. Each vertex gets the first (or least used, if balanced) color that fits.
+func ColorGraphGreedy(g CellGraph, colorCount int, balanced bool) []int {
+	colors := make([]int, len(g))
+	if colorCount <= 0 {
+		return colors
+	}
+	for i := range colors {
+		colors[i] = -1
+	}
+
+	usage := make([]int, colorCount)
+	for _, v := range smallestLastOrder(g) {
+		colors[v] = pickColor(g, v, colors, usage, colorCount, balanced)
+		usage[colors[v]]++
+	}
+
+	return colors
+}
+
+// Swaps the colors a and b in the connected a/b-chain that contains start (Kempe chain).
+// Returns all vertices that changed, so the swap can be undone by swapping them again.
+func swapKempeChain(g CellGraph, colors []int, usage []int, start, a, b int) []int {
+	chain := []int{start}
+	visited := map[int]bool{start: true}
+	for i := 0; i < len(chain); i++ {
+		for _, n := range g[chain[i]] {
+			if !visited[n] && (colors[n] == a || colors[n] == b) {
+				visited[n] = true
+				chain = append(chain, n)
+			}
+		}
+	}
+	swapColors(chain, colors, usage, a, b)
+	return chain
+}
+
+func swapColors(vertices []int, colors []int, usage []int, a, b int) {
+	for _, v := range vertices {
+		if colors[v] == a {
+			colors[v] = b
+			usage[a]--
+			usage[b]++
+		} else {
+			colors[v] = a
+			usage[b]--
+			usage[a]++
+		}
+	}
+}
+
+func freeColor(g CellGraph, v int, colors []int, colorCount int) bool {
+	used := make([]bool, colorCount)
+	for _, n := range g[v] {
+		if colors[n] >= 0 {
+			used[colors[n]] = true
+		}
+	}
+	for _, u := range used {
+		if !u {
+			return true
+		}
+	}
+	return false
+}
+
+// If all colors are taken by neighbors of v, tries to free one by swapping a Kempe chain
+// starting at one of the neighbors. This is the trick from the classic five color proof.
+func tryKempeRecoloring(g CellGraph, v int, colors []int, usage []int, colorCount int) bool {
+	for _, n := range g[v] {
+		a := colors[n]
+		if a < 0 {
+			continue
+		}
+		for b := 0; b < colorCount; b++ {
+			if b == a {
+				continue
+			}
+			chain := swapKempeChain(g, colors, usage, n, a, b)
+			if freeColor(g, v, colors, colorCount) {
+				return true
+			}
+			swapColors(chain, colors, usage, a, b)
+		}
+	}
+	return false
+}
+
+// Tries to find a proper coloring with at most colorCount (normally four) colors. Voronoi neighbor graphs are planar,
+// so four colors are always enough in theory. Vertices are colored in smallest-last order and whenever a vertex
+// is blocked, Kempe chains are swapped to free a color. Remaining conflicts are then repaired by local search.
+// If that fails within the step budget, the greedy coloring with all colorCount colors is used instead, if it is proper.
+// Otherwise the result might still contain a few equal neighbors.
+func ColorGraphFourColor(g CellGraph, colorCount int, balanced bool) []int {
+	availableColors := colorCount
+	if colorCount > 4 {
+		colorCount = 4
+	}
+	colors := make([]int, len(g))
+	if colorCount <= 0 {
+		return colors
+	}
+	for i := range colors {
+		colors[i] = -1
+	}
+
+	usage := make([]int, colorCount)
+	setColor := func(v int, ignoreBalance bool) {
+		colors[v] = pickColor(g, v, colors, usage, colorCount, balanced && !ignoreBalance)
+		usage[colors[v]]++
+	}
+	unsetColor := func(v int) {
+		usage[colors[v]]--
+		colors[v] = -1
+	}
+
+	conflicting := []int{}
+	for _, v := range smallestLastOrder(g) {
+		if !freeColor(g, v, colors, colorCount) && !tryKempeRecoloring(g, v, colors, usage, colorCount) {
+			conflicting = append(conflicting, v)
+		}
+		setColor(v, false)
+	}
+
+	// Local search for whatever is left. Uncolor a conflicting vertex and try again with Kempe chains.
+	// conflicting is a queue, so pushed problems are looked at after the ones already waiting.
+	for steps, next := 0, 0; next < len(conflicting) && steps < g_graphColoringMaxSteps; steps++ {
+		v := conflicting[next]
+		next++
+
+		unsetColor(v)
+		if !freeColor(g, v, colors, colorCount) && !tryKempeRecoloring(g, v, colors, usage, colorCount) {
+			// Push the problem to a neighbor and hope it has more options.
+			setColor(v, true)
+			for _, n := range g[v] {
+				if colors[n] == colors[v] {
+					unsetColor(n)
+					setColor(n, true)
+					conflicting = append(conflicting, n)
+				}
+			}
+			continue
+		}
+		setColor(v, false)
+	}
+
+	if !g.IsProperColoring(colors) {
+		if greedy := ColorGraphGreedy(g, availableColors, balanced); g.IsProperColoring(greedy) {
+			return greedy
+		}
+	}
+	return colors
+}
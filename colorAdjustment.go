@@ -0,0 +1,95 @@
+// colorAdjustment
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Stylization of the cell colors, applied after sampling. Keep in sync with adjustColor in triangles.vert!
+type ColorAdjustment struct {
+	// 1 keeps the colors, 0 is grey.
+	Saturation float32
+	// Added to every channel.
+	Brightness float32
+	// Scales the distance to middle grey, 1 keeps the colors.
+	Contrast float32
+	// Rotation around the grey axis in radians.
+	HueShift float32
+	// Maximal random offset per channel and cell.
+	Jitter float32
+	Seed   uint32
+	// Number of values per channel, less than 2 disables posterization.
+	PosterizeLevels int
+}
+
+var g_defaultColorAdjustment = ColorAdjustment{Saturation: 1, Contrast: 1}
+
+func (a ColorAdjustment) Active() bool {
+	neutral := g_defaultColorAdjustment
+	neutral.Seed = a.Seed
+	if a.PosterizeLevels < 2 {
+		neutral.PosterizeLevels = a.PosterizeLevels
+	}
+	return a != neutral
+}
+
+// Integer hash (lowbias32), the same as in triangles.vert.
+func hashUint(x uint32) uint32 {
+	x ^= x >> 16
+	x *= 0x7feb352d
+	x ^= x >> 15
+	x *= 0x846ca68b
+	x ^= x >> 16
+	return x
+}
+
+// Random value in [-1,1].
+func hashToSigned(h uint32) float32 {
+	return float32(h&0xffffff)/float32(0xffffff)*2 - 1
+}
+
+// uv identifies the cell (it is the same for all vertices of a flat cell), so every cell gets its own jitter.
+func (a ColorAdjustment) Apply(c mgl32.Vec3, uv mgl32.Vec2) mgl32.Vec3 {
+	if a.HueShift != 0 {
+		// Rodrigues rotation around (1,1,1).
+		k := mgl32.Vec3{1, 1, 1}.Normalize()
+		cos := float32(math.Cos(float64(a.HueShift)))
+		sin := float32(math.Sin(float64(a.HueShift)))
+		c = c.Mul(cos).Add(k.Cross(c).Mul(sin)).Add(k.Mul(k.Dot(c) * (1 - cos)))
+	}
+
+	luminance := c.Dot(mgl32.Vec3{0.2126, 0.7152, 0.0722})
+	c = mgl32.Vec3{luminance, luminance, luminance}.Add(c.Sub(mgl32.Vec3{luminance, luminance, luminance}).Mul(a.Saturation))
+
+	half := mgl32.Vec3{0.5, 0.5, 0.5}
+	c = c.Sub(half).Mul(a.Contrast).Add(half)
+	c = c.Add(mgl32.Vec3{a.Brightness, a.Brightness, a.Brightness})
+
+	if a.Jitter != 0 {
+		h := hashUint(math.Float32bits(uv.X()) ^ hashUint(math.Float32bits(uv.Y())^hashUint(a.Seed)))
+		for i := 0; i < 3; i++ {
+			h = hashUint(h)
+			c[i] += a.Jitter * hashToSigned(h)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		c[i] = float32(math.Max(0, math.Min(1, float64(c[i]))))
+		if a.PosterizeLevels >= 2 {
+			steps := float64(a.PosterizeLevels - 1)
+			c[i] = float32(math.Round(float64(c[i])*steps) / steps)
+		}
+	}
+	return c
+}
+
+// Adjusts one color per cell. uvs are the cell identifiers like in Apply.
+func adjustColors(colors []mgl32.Vec4, uvs []mgl32.Vec2, a ColorAdjustment) []mgl32.Vec4 {
+	adjusted := make([]mgl32.Vec4, len(colors))
+	for i, c := range colors {
+		adjusted[i] = a.Apply(c.Vec3(), uvs[i]).Vec4(c.W())
+	}
+	return adjusted
+}
@@ -0,0 +1,54 @@
+// colorGeometry
+package main
+
+import (
+	"unsafe"
+
+	geo "github.com/MauriceGit/mtGeometry"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Same layout as geo.Mesh, but with an additional color per vertex (attribute location 3).
+// Used whenever the color of a face is calculated on the CPU instead of sampled in the shader.
+type ColorMesh struct {
+	Pos    mgl32.Vec3
+	Normal mgl32.Vec3
+	UV     mgl32.Vec2
+	Color  mgl32.Vec4
+}
+
+func generateColorGeometryArrayAttributes(mesh *[]ColorMesh, vertexCount int) geo.ArrayGeometry {
+	geometry := geo.ArrayGeometry{}
+
+	var m ColorMesh
+	stride := int32(unsafe.Sizeof(m))
+	var v mgl32.Vec3
+	vStride := int(unsafe.Sizeof(v))
+	var uv mgl32.Vec2
+	uvStride := int(unsafe.Sizeof(uv))
+
+	gl.GenBuffers(1, &geometry.ArrayBuffer)
+	gl.BindBuffer(gl.ARRAY_BUFFER, geometry.ArrayBuffer)
+	if vertexCount > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, int(stride)*vertexCount, gl.Ptr(*mesh), gl.STATIC_DRAW)
+	}
+
+	gl.GenVertexArrays(1, &geometry.VertexBuffer)
+	gl.BindVertexArray(geometry.VertexBuffer)
+
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride, gl.PtrOffset(0))
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(1, 3, gl.FLOAT, true, stride, gl.PtrOffset(vStride))
+	gl.EnableVertexAttribArray(2)
+	gl.VertexAttribPointer(2, 2, gl.FLOAT, false, stride, gl.PtrOffset(2*vStride))
+	gl.EnableVertexAttribArray(3)
+	gl.VertexAttribPointer(3, 4, gl.FLOAT, false, stride, gl.PtrOffset(2*vStride+uvStride))
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+
+	geometry.VertexCount = int32(vertexCount)
+
+	return geometry
+}
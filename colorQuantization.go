@@ -0,0 +1,239 @@
+// colorQuantization
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// Every cell keeps its own color.
+	QUANTIZE_NONE = iota
+	// Splits the color box with the largest extent at its median until there are enough boxes.
+	QUANTIZE_MEDIAN_CUT = iota
+	QUANTIZE_KMEANS     = iota
+	// The palette from the palette coloring entry.
+	QUANTIZE_USER_PALETTE = iota
+)
+
+const g_quantizeKMeansIterations = 16
+
+// Reduces colors to at most count colors. Colors are compared and averaged in space.
+// For QUANTIZE_USER_PALETTE the user palette is returned as is.
+func CreateQuantizedPalette(colors []mgl32.Vec4, method, count, space int, userPalette []mgl32.Vec4) []mgl32.Vec4 {
+	if method == QUANTIZE_USER_PALETTE {
+		return userPalette
+	}
+	if len(colors) == 0 || count <= 0 {
+		return nil
+	}
+
+	converted := make([]mgl32.Vec3, len(colors))
+	for i, c := range colors {
+		converted[i] = toColorSpace(c.Vec3(), space)
+	}
+
+	var palette []mgl32.Vec3
+	switch method {
+	case QUANTIZE_MEDIAN_CUT:
+		palette = medianCutPalette(converted, count)
+	case QUANTIZE_KMEANS:
+		centers, sizes := kMeansColors(converted, count, g_quantizeKMeansIterations)
+		for i, c := range centers {
+			// Clusters that lost all their colors would be unused inks.
+			if sizes[i] > 0 {
+				palette = append(palette, c)
+			}
+		}
+	default:
+		return nil
+	}
+
+	result := make([]mgl32.Vec4, len(palette))
+	for i, c := range palette {
+		result[i] = fromColorSpace(c, space).Vec4(1)
+	}
+	return result
+}
+
+func medianCutPalette(colors []mgl32.Vec3, count int) []mgl32.Vec3 {
+	// Channel with the largest extent and the extent itself.
+	longestChannel := func(box []mgl32.Vec3) (int, float32) {
+		// Not [0,1] for every color space.
+		min := box[0]
+		max := box[0]
+		for _, c := range box {
+			for i := 0; i < 3; i++ {
+				min[i] = float32(math.Min(float64(min[i]), float64(c[i])))
+				max[i] = float32(math.Max(float64(max[i]), float64(c[i])))
+			}
+		}
+		channel := 0
+		for i := 1; i < 3; i++ {
+			if max[i]-min[i] > max[channel]-min[channel] {
+				channel = i
+			}
+		}
+		return channel, max[channel] - min[channel]
+	}
+
+	boxes := [][]mgl32.Vec3{append([]mgl32.Vec3{}, colors...)}
+	for len(boxes) < count {
+		split := -1
+		splitChannel := 0
+		var largest float32
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, extent := longestChannel(box)
+			if extent > largest {
+				split, splitChannel, largest = i, channel, extent
+			}
+		}
+		// All remaining boxes contain only one color.
+		if split < 0 {
+			break
+		}
+
+		box := boxes[split]
+		sort.Slice(box, func(a, b int) bool { return box[a][splitChannel] < box[b][splitChannel] })
+		boxes[split] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+
+	palette := make([]mgl32.Vec3, len(boxes))
+	for i, box := range boxes {
+		for _, c := range box {
+			palette[i] = palette[i].Add(c)
+		}
+		palette[i] = palette[i].Mul(1.0 / float32(len(box)))
+	}
+	return palette
+}
+
+// Replaces every color by the nearest palette color (measured in space). Alpha is kept.
+func remapToPalette(colors []mgl32.Vec4, palette []mgl32.Vec4, space int) []mgl32.Vec4 {
+	if len(palette) == 0 {
+		return colors
+	}
+	convertedPalette := make([]mgl32.Vec3, len(palette))
+	for i, c := range palette {
+		convertedPalette[i] = toColorSpace(c.Vec3(), space)
+	}
+
+	remapped := make([]mgl32.Vec4, len(colors))
+	for i, c := range colors {
+		converted := toColorSpace(c.Vec3(), space)
+		best := 0
+		for p := 1; p < len(palette); p++ {
+			if colorDistanceSquared(converted, convertedPalette[p]) < colorDistanceSquared(converted, convertedPalette[best]) {
+				best = p
+			}
+		}
+		remapped[i] = palette[best].Vec3().Vec4(c.W())
+	}
+	return remapped
+}
+
+func colorTo8Bit(c mgl32.Vec4) (r, g, b uint8) {
+	to8 := func(f float32) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Round(float64(f)*255))))
+	}
+	return to8(c[0]), to8(c[1]), to8(c[2])
+}
+
+func colorToHex(c mgl32.Vec4) string {
+	r, g, b := colorTo8Bit(c)
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// GIMP palette (also read by Inkscape and Krita).
+func encodeGPL(palette []mgl32.Vec4, name string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "GIMP Palette\nName: %s\nColumns: %d\n#\n", name, len(palette))
+	for _, c := range palette {
+		r, g, b := colorTo8Bit(c)
+		fmt.Fprintf(&buf, "%3d %3d %3d\t%s\n", r, g, b, colorToHex(c))
+	}
+	return buf.Bytes()
+}
+
+// Adobe Swatch Exchange: big endian, one color entry block per color in the RGB color model.
+func encodeASE(palette []mgl32.Vec4) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("ASEF")
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(palette)))
+
+	for _, c := range palette {
+		// Null terminated UTF-16 name.
+		name := append(utf16.Encode([]rune(colorToHex(c))), 0)
+
+		binary.Write(&buf, binary.BigEndian, uint16(0x0001))
+		binary.Write(&buf, binary.BigEndian, uint32(2+2*len(name)+4+3*4+2))
+		binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+		binary.Write(&buf, binary.BigEndian, name)
+		buf.WriteString("RGB ")
+		binary.Write(&buf, binary.BigEndian, []float32{c[0], c[1], c[2]})
+		// Global color.
+		binary.Write(&buf, binary.BigEndian, uint16(0))
+	}
+	return buf.Bytes()
+}
+
+func encodePaletteJSON(palette []mgl32.Vec4, name string) ([]byte, error) {
+	type jsonColor struct {
+		Hex     string
+		R, G, B uint8
+	}
+	colors := make([]jsonColor, len(palette))
+	for i, c := range palette {
+		r, g, b := colorTo8Bit(c)
+		colors[i] = jsonColor{colorToHex(c), r, g, b}
+	}
+	return json.MarshalIndent(struct {
+		Name   string
+		Colors []jsonColor
+	}{name, colors}, "", "  ")
+}
+
+// Writes the palette as .gpl, .ase or .json, depending on the file extension.
+func WritePalette(path string, palette []mgl32.Vec4) error {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpl":
+		data = encodeGPL(palette, name)
+	case ".ase":
+		data = encodeASE(palette)
+	case ".json":
+		data, err = encodePaletteJSON(palette, name)
+	default:
+		return fmt.Errorf("unknown palette format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
@@ -0,0 +1,70 @@
+// colorSource
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// How the color image is fitted onto the geometry image.
+const (
+	// Both axes independently to the full size.
+	COLOR_FIT_STRETCH = iota
+	// The whole color image is visible, keeping its aspect ratio.
+	COLOR_FIT_CONTAIN = iota
+	// The color image covers everything, keeping its aspect ratio.
+	COLOR_FIT_COVER = iota
+)
+
+type ImageAlignment struct {
+	Fit int
+	// Additional zoom on top of the fit, 1 keeps the fitted size.
+	Scale float64
+	// Shift of the color image in fractions of the geometry image size. Positive is right and down.
+	OffsetX, OffsetY float64
+}
+
+var g_defaultImageAlignment = ImageAlignment{Fit: COLOR_FIT_STRETCH, Scale: 1}
+
+// Resamples src to width x height, aligned like a. All color sampling can then use the same uv as the geometry image.
+// Areas outside of src repeat its border pixels, like CLAMP_TO_EDGE in the shaders.
+func alignColorImage(src *image.RGBA, width, height int, a ImageAlignment) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw := float64(src.Rect.Dx())
+	sh := float64(src.Rect.Dy())
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+
+	// Source pixels per destination pixel.
+	scaleX := sw / float64(width)
+	scaleY := sh / float64(height)
+	switch a.Fit {
+	case COLOR_FIT_CONTAIN:
+		scaleX = math.Max(scaleX, scaleY)
+		scaleY = scaleX
+	case COLOR_FIT_COVER:
+		scaleX = math.Min(scaleX, scaleY)
+		scaleY = scaleX
+	}
+	if a.Scale > 0 {
+		scaleX /= a.Scale
+		scaleY /= a.Scale
+	}
+
+	clamp := func(v float64, max int) int {
+		return int(math.Max(0, math.Min(float64(max-1), math.Floor(v))))
+	}
+
+	for y := 0; y < height; y++ {
+		sy := clamp((float64(y)+0.5-float64(height)*(0.5+a.OffsetY))*scaleY+sh/2, src.Rect.Dy())
+		for x := 0; x < width; x++ {
+			sx := clamp((float64(x)+0.5-float64(width)*(0.5+a.OffsetX))*scaleX+sw/2, src.Rect.Dx())
+
+			si := src.PixOffset(sx+src.Rect.Min.X, sy+src.Rect.Min.Y)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,78 @@
+// Color space conversions of colorSpace.go for the shaders. Pulled in with #include, see shaders.go.
+
+// Keep these in sync with colorSpace.go!
+#define COLOR_SPACE_SRGB    0
+#define COLOR_SPACE_LINEAR  1
+#define COLOR_SPACE_CIELAB  2
+#define COLOR_SPACE_OKLAB   3
+
+// Colors are averaged in this color space.
+uniform int colorSpace;
+
+vec3 srgbToLinear(vec3 c) {
+    return mix(c / 12.92, pow((c + 0.055) / 1.055, vec3(2.4)), greaterThan(c, vec3(0.04045)));
+}
+
+vec3 linearToSRGB(vec3 c) {
+    return mix(c * 12.92, 1.055 * pow(c, vec3(1.0/2.4)) - 0.055, greaterThan(c, vec3(0.0031308)));
+}
+
+vec3 cbrt(vec3 v) {
+    return sign(v) * pow(abs(v), vec3(1.0/3.0));
+}
+
+vec3 labF(vec3 t) {
+    const float delta = 6.0/29.0;
+    return mix(t / (3.0*delta*delta) + 4.0/29.0, cbrt(t), greaterThan(t, vec3(delta*delta*delta)));
+}
+
+vec3 labFInverse(vec3 t) {
+    const float delta = 6.0/29.0;
+    return mix(3.0*delta*delta * (t - 4.0/29.0), t*t*t, greaterThan(t, vec3(delta)));
+}
+
+// GLSL matrices are column major, so the rows of the usual matrices are written as columns and multiplied from the left.
+vec3 toColorSpace(vec3 c) {
+    if (colorSpace == COLOR_SPACE_SRGB) {
+        return c;
+    }
+    vec3 rgb = srgbToLinear(c);
+    if (colorSpace == COLOR_SPACE_CIELAB) {
+        vec3 xyz = rgb * mat3(0.4124564, 0.3575761, 0.1804375,
+                              0.2126729, 0.7151522, 0.0721750,
+                              0.0193339, 0.1191920, 0.9503041);
+        vec3 f = labF(xyz / vec3(0.95047, 1.0, 1.08883));
+        return vec3(116.0*f.y - 16.0, 500.0*(f.x - f.y), 200.0*(f.y - f.z));
+    }
+    if (colorSpace == COLOR_SPACE_OKLAB) {
+        vec3 lms = cbrt(rgb * mat3(0.4122214708, 0.5363325363, 0.0514459929,
+                                   0.2119034982, 0.6806995451, 0.1073969566,
+                                   0.0883024619, 0.2817188376, 0.6299787005));
+        return lms * mat3(0.2104542553,  0.7936177850, -0.0040720468,
+                          1.9779984951, -2.4285922050,  0.4505937099,
+                          0.0259040371,  0.7827717662, -0.8086757660);
+    }
+    return rgb;
+}
+
+vec3 fromColorSpace(vec3 c) {
+    if (colorSpace == COLOR_SPACE_SRGB) {
+        return c;
+    }
+    vec3 rgb = c;
+    if (colorSpace == COLOR_SPACE_CIELAB) {
+        float fy = (c.x + 16.0) / 116.0;
+        vec3 xyz = labFInverse(vec3(fy + c.y/500.0, fy, fy - c.z/200.0)) * vec3(0.95047, 1.0, 1.08883);
+        rgb = xyz * mat3( 3.2404542, -1.5371385, -0.4985314,
+                         -0.9692660,  1.8760108,  0.0415560,
+                          0.0556434, -0.2040259,  1.0572252);
+    } else if (colorSpace == COLOR_SPACE_OKLAB) {
+        vec3 lms = c * mat3(1.0,  0.3963377774,  0.2158037573,
+                            1.0, -0.1055613458, -0.0638541728,
+                            1.0, -0.0894841775, -1.2914855480);
+        rgb = (lms*lms*lms) * mat3( 4.0767416621, -3.3077115913,  0.2309699292,
+                                   -1.2684380046,  2.6097574011, -0.3413193965,
+                                   -0.0041960863, -0.7034186147,  1.7076147010);
+    }
+    return clamp(linearToSRGB(max(rgb, vec3(0))), 0.0, 1.0);
+}
@@ -0,0 +1,133 @@
+// colorSpace
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Color spaces that colors are averaged in. Keep these in sync with the shaders!
+const (
+	// Raw texture values, like the GPU does it without sRGB textures. Darkens mixed colors.
+	COLOR_SPACE_SRGB = iota
+	COLOR_SPACE_LINEAR
+	COLOR_SPACE_CIELAB
+	COLOR_SPACE_OKLAB
+)
+
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1.0/2.4) - 0.055
+}
+
+// D65 white point.
+var g_whiteX, g_whiteY, g_whiteZ = 0.95047, 1.0, 1.08883
+
+func labF(t float64) float64 {
+	const delta = 6.0 / 29.0
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29.0
+}
+
+func labFInverse(t float64) float64 {
+	const delta = 6.0 / 29.0
+	if t > delta {
+		return t * t * t
+	}
+	return 3 * delta * delta * (t - 4.0/29.0)
+}
+
+// Converts an sRGB color (as stored in the image) into space.
+func toColorSpace(c mgl32.Vec3, space int) mgl32.Vec3 {
+	if space == COLOR_SPACE_SRGB {
+		return c
+	}
+
+	r, g, b := srgbToLinear(float64(c[0])), srgbToLinear(float64(c[1])), srgbToLinear(float64(c[2]))
+
+	switch space {
+	case COLOR_SPACE_CIELAB:
+		x := labF((0.4124564*r + 0.3575761*g + 0.1804375*b) / g_whiteX)
+		y := labF((0.2126729*r + 0.7151522*g + 0.0721750*b) / g_whiteY)
+		z := labF((0.0193339*r + 0.1191920*g + 0.9503041*b) / g_whiteZ)
+		return mgl32.Vec3{float32(116*y - 16), float32(500 * (x - y)), float32(200 * (y - z))}
+	case COLOR_SPACE_OKLAB:
+		l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+		m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+		s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+		return mgl32.Vec3{
+			float32(0.2104542553*l + 0.7936177850*m - 0.0040720468*s),
+			float32(1.9779984951*l - 2.4285922050*m + 0.4505937099*s),
+			float32(0.0259040371*l + 0.7827717662*m - 0.8086757660*s),
+		}
+	}
+	return mgl32.Vec3{float32(r), float32(g), float32(b)}
+}
+
+// Converts a color from space back to sRGB, clamped to [0,1].
+func fromColorSpace(c mgl32.Vec3, space int) mgl32.Vec3 {
+	if space == COLOR_SPACE_SRGB {
+		return c
+	}
+
+	r, g, b := float64(c[0]), float64(c[1]), float64(c[2])
+
+	switch space {
+	case COLOR_SPACE_CIELAB:
+		fy := (r + 16) / 116
+		x := labFInverse(fy+g/500) * g_whiteX
+		y := labFInverse(fy) * g_whiteY
+		z := labFInverse(fy-b/200) * g_whiteZ
+		r, g, b = 3.2404542*x-1.5371385*y-0.4985314*z, -0.9692660*x+1.8760108*y+0.0415560*z, 0.0556434*x-0.2040259*y+1.0572252*z
+	case COLOR_SPACE_OKLAB:
+		l := r + 0.3963377774*g + 0.2158037573*b
+		m := r - 0.1055613458*g - 0.0638541728*b
+		s := r - 0.0894841775*g - 1.2914855480*b
+		l, m, s = l*l*l, m*m*m, s*s*s
+		r, g, b = 4.0767416621*l-3.3077115913*m+0.2309699292*s, -1.2684380046*l+2.6097574011*m-0.3413193965*s, -0.0041960863*l-0.7034186147*m+1.7076147010*s
+	}
+
+	clamp := func(v float64) float32 {
+		return float32(math.Max(0, math.Min(1, linearToSRGB(math.Max(0, v)))))
+	}
+	return mgl32.Vec3{clamp(r), clamp(g), clamp(b)}
+}
+
+// Mean of sRGB colors with alpha. Colors are weighted by their alpha (like averaging premultiplied colors),
+// so transparent pixels do not darken the result. Alpha is the plain mean.
+func averageColorsAlpha(colors []mgl32.Vec4, space int) mgl32.Vec4 {
+	sum := mgl32.Vec3{}
+	var alpha float32
+	for _, c := range colors {
+		sum = sum.Add(toColorSpace(c.Vec3(), space).Mul(c[3]))
+		alpha += c[3]
+	}
+	if alpha <= 0 {
+		return mgl32.Vec4{}
+	}
+	return fromColorSpace(sum.Mul(1/alpha), space).Vec4(alpha / float32(len(colors)))
+}
+
+// Mean of sRGB colors, averaged in space.
+func averageColors(colors []mgl32.Vec3, space int) mgl32.Vec3 {
+	if len(colors) == 0 {
+		return mgl32.Vec3{}
+	}
+	sum := mgl32.Vec3{}
+	for _, c := range colors {
+		sum = sum.Add(toColorSpace(c, space))
+	}
+	return fromColorSpace(sum.Mul(1.0/float32(len(colors))), space)
+}
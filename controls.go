@@ -13,6 +13,54 @@ import (
 const (
 	INITIAL_POINT_COUNT = 192
 	DEFAULT_IMAGE       = "./Images/apple.png"
+	DEFAULT_MOSAIC_GAP  = 4
+	DEFAULT_CELL_RADIUS = 8
+	DEFAULT_CELL_SMOOTH = 3
+	// In percent of the expected point distance.
+	DEFAULT_ALPHA_RADIUS = 200
+	DEFAULT_KNN_COUNT    = 3
+	// In tenths, so the slider can do 1.0 to 10.0.
+	DEFAULT_MINKOWSKI_EXPONENT = 30
+	// In percent of the full color range.
+	DEFAULT_ADAPTIVE_THRESHOLD = 8
+	DEFAULT_ADAPTIVE_DEPTH     = 4
+	DEFAULT_ADAPTIVE_BUDGET    = 5000
+	DEFAULT_OPTIMIZER_STEPS    = 2000
+	// In seconds.
+	DEFAULT_OPTIMIZER_TIME = 5
+	// In percent.
+	DEFAULT_COLOR_IMAGE_SCALE = 100
+
+	DEFAULT_QUANTIZE_COLORS = 8
+	DEFAULT_SATURATION      = 100
+	DEFAULT_CONTRAST        = 100
+
+	// In pixels.
+	DEFAULT_RELIEF_HEIGHT = 10
+	// In degrees.
+	DEFAULT_LIGHT_AZIMUTH   = 135
+	DEFAULT_LIGHT_ELEVATION = 45
+	// In percent.
+	DEFAULT_LIGHT_AMBIENT   = 30
+	DEFAULT_LIGHT_SPECULAR  = 30
+	DEFAULT_LIGHT_SHININESS = 32
+
+	// In pixels.
+	DEFAULT_LEADING_WIDTH = 6
+	// In percent.
+	DEFAULT_GLASS_GLOW       = 25
+	DEFAULT_GLASS_TEXTURE    = 8
+	DEFAULT_GLASS_SATURATION = 120
+
+	// In tenths of a pixel.
+	DEFAULT_LINE_WIDTH = 10
+	// In pixels.
+	DEFAULT_POINT_SIZE = 5
+	// Samples per pixel.
+	DEFAULT_MULTISAMPLE_COUNT = 4
+	// In pixels, only used for custom export sizes.
+	DEFAULT_EXPORT_WIDTH = 4000
+	DEFAULT_EXPORT_DPI   = 300
 )
 
 var (
@@ -23,6 +71,14 @@ var (
 	dLineColor        = [...]float64{1, 1, 1, 1}
 	pointColor        = [...]float64{1, 1, 1, 1}
 	chColor           = [...]float64{1, 1, 1, 1}
+	groutColor        = [...]float64{0.15, 0.15, 0.15, 1}
+	backgroundColor   = [...]float64{0, 0, 0, 1}
+	leadingColor      = [...]float64{0.08, 0.08, 0.08, 1}
+	alphaShapeColor   = [...]float64{1, 0.8, 0.2, 1}
+	mstColor          = [...]float64{1, 0.3, 0.3, 1}
+	gabrielColor      = [...]float64{0.3, 1, 0.3, 1}
+	rngColor          = [...]float64{0.3, 0.6, 1, 1}
+	knnColor          = [...]float64{1, 1, 1, 1}
 )
 
 func createFileOpenButton(mainwin *ui.Window, c chan func()) *ui.Button {
@@ -61,15 +117,204 @@ func createFileSaveButton(mainwin *ui.Window, c chan func()) *ui.Button {
 	return button
 }
 
+func createStatisticsExportButton(mainwin *ui.Window, c chan func()) *ui.Button {
+	button := ui.NewButton("Export Statistics")
+	button.OnClicked(func(*ui.Button) {
+		filename := ui.SaveFile(mainwin)
+		if filename != "" {
+
+			if !strings.HasSuffix(filename, ".csv") && !strings.HasSuffix(filename, ".json") {
+				filename = filename + ".csv"
+			}
+
+			c <- func() {
+				ExportStatistics(filename)
+			}
+		}
+	})
+	return button
+}
+
+// Renders the image offscreen at the source resolution or a custom width, independent of the window size.
+// The Worley noise is exported at the same size.
+func createExportControls(mainwin *ui.Window, c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	size := ui.NewCombobox()
+	size.Append("Source Resolution")
+	size.Append("Custom Width")
+	widthLable := ui.NewLabel("Width")
+	width := ui.NewSpinbox(16, 32768)
+	dpiLable := ui.NewLabel("DPI")
+	dpi := ui.NewSpinbox(0, 2400)
+	export := ui.NewButton("Export Image")
+
+	grid.Append(size, 0, 0, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(widthLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(width, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(dpiLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(dpi, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(export, 0, 3, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+
+	size.SetSelected(0)
+	width.SetValue(DEFAULT_EXPORT_WIDTH)
+	width.Disable()
+	dpi.SetValue(DEFAULT_EXPORT_DPI)
+
+	// 0 is the source resolution.
+	exportWidth := func() int {
+		if size.Selected() == 1 {
+			return width.Value()
+		}
+		return 0
+	}
+
+	size.OnSelected(func(*ui.Combobox) {
+		if size.Selected() == 1 {
+			width.Enable()
+		} else {
+			width.Disable()
+		}
+		value := exportWidth()
+		c <- func() {
+			SetExportWidth(value)
+		}
+	})
+	width.OnChanged(func(*ui.Spinbox) {
+		value := exportWidth()
+		c <- func() {
+			SetExportWidth(value)
+		}
+	})
+	dpi.OnChanged(func(*ui.Spinbox) {
+		value := dpi.Value()
+		c <- func() {
+			SetExportDPI(value)
+		}
+	})
+	export.OnClicked(func(*ui.Button) {
+		filename := ui.SaveFile(mainwin)
+		if filename != "" {
+
+			if !strings.HasSuffix(filename, ".png") {
+				filename = filename + ".png"
+			}
+
+			c <- func() {
+				ExportImage(filename)
+			}
+		}
+	})
+
+	return grid
+}
+
 func createImageLoadSaveOperations(mainwin *ui.Window, c chan func()) *ui.Grid {
 	grid := ui.NewGrid()
 	grid.SetPadded(true)
 
 	imageLoad := createFileOpenButton(mainwin, c)
 	imageSave := createFileSaveButton(mainwin, c)
+	statistics := createStatisticsExportButton(mainwin, c)
 
 	grid.Append(imageLoad, 0, 0, 1, 1, false, ui.AlignFill, true, ui.AlignFill)
 	grid.Append(imageSave, 1, 0, 1, 1, false, ui.AlignFill, true, ui.AlignFill)
+	grid.Append(statistics, 0, 1, 2, 1, false, ui.AlignFill, true, ui.AlignFill)
+
+	return grid
+}
+
+// A second image for the colors only. The geometry still follows the opened image.
+func createColorImageControls(mainwin *ui.Window, c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	open := ui.NewButton("Open Color Image")
+	useMain := ui.NewButton("Use Main Image")
+	fit := ui.NewCombobox()
+	fit.Append("Stretch")
+	fit.Append("Fit Inside")
+	fit.Append("Cover")
+	scaleLable := ui.NewLabel("Scale")
+	scale := ui.NewSlider(10, 400)
+	offsetXLable := ui.NewLabel("Offset X")
+	offsetX := ui.NewSlider(-100, 100)
+	offsetYLable := ui.NewLabel("Offset Y")
+	offsetY := ui.NewSlider(-100, 100)
+
+	grid.Append(open, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(useMain, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(fit, 0, 1, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(scaleLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(scale, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(offsetXLable, 0, 3, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(offsetX, 1, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(offsetYLable, 0, 4, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(offsetY, 1, 4, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	fit.SetSelected(0)
+	scale.SetValue(DEFAULT_COLOR_IMAGE_SCALE)
+	offsetX.SetValue(0)
+	offsetY.SetValue(0)
+
+	// Colors calculated on the CPU depend on the color image, so they are calculated again. The points stay.
+	open.OnClicked(func(*ui.Button) {
+		filename := ui.OpenFile(mainwin)
+		if filename != "" {
+			c <- func() {
+				SetColorImage(filename)
+				ReadyForRecolor(true)
+				ReadyForRender(true)
+			}
+		}
+	})
+	useMain.OnClicked(func(*ui.Button) {
+		c <- func() {
+			SetColorImage("")
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	fit.OnSelected(func(*ui.Combobox) {
+		selectedIndex := fit.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetColorImageFit(COLOR_FIT_STRETCH)
+			case 1:
+				SetColorImageFit(COLOR_FIT_CONTAIN)
+			case 2:
+				SetColorImageFit(COLOR_FIT_COVER)
+			}
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	scale.OnChanged(func(*ui.Slider) {
+		value := scale.Value()
+		c <- func() {
+			SetColorImageScale(float64(value) / 100.0)
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	offsetX.OnChanged(func(*ui.Slider) {
+		value := offsetX.Value()
+		c <- func() {
+			SetColorImageOffsetX(float64(value) / 100.0)
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	offsetY.OnChanged(func(*ui.Slider) {
+		value := offsetY.Value()
+		c <- func() {
+			SetColorImageOffsetY(float64(value) / 100.0)
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
 
 	return grid
 }
@@ -107,6 +352,7 @@ func createPointDistributionButtons(c chan func()) *ui.RadioButtons {
 	rb.Append("Poisson Disk")
 	rb.Append("Random")
 	rb.Append("Grid")
+	rb.Append("Adaptive (Quadtree)")
 
 	rb.SetSelected(0)
 
@@ -125,6 +371,10 @@ func createPointDistributionButtons(c chan func()) *ui.RadioButtons {
 			c <- func() {
 				SetPointDistributionMethod(POINT_DISTRIBUTION_GRID)
 			}
+		case 3:
+			c <- func() {
+				SetPointDistributionMethod(POINT_DISTRIBUTION_ADAPTIVE)
+			}
 		}
 
 		c <- func() {
@@ -140,6 +390,11 @@ func createFaceRenderingButtons(c chan func()) *ui.RadioButtons {
 	rb := ui.NewRadioButtons()
 	rb.Append("Delaunay Triangles")
 	rb.Append("Voronoi Cells")
+	rb.Append("Metric Voronoi Cells")
+	rb.Append("Mosaic Tiles")
+	rb.Append("Palette Cells")
+	rb.Append("Worley Noise")
+	rb.Append("Stained Glass")
 	rb.Append("Nothing")
 
 	rb.SetSelected(1)
@@ -152,6 +407,21 @@ func createFaceRenderingButtons(c chan func()) *ui.RadioButtons {
 		c <- func() {
 			SetRenderVoronoiCells(selectedIndex == 1)
 		}
+		c <- func() {
+			SetRenderMetricVoronoi(selectedIndex == 2)
+		}
+		c <- func() {
+			SetRenderMosaic(selectedIndex == 3)
+		}
+		c <- func() {
+			SetRenderPaletteCells(selectedIndex == 4)
+		}
+		c <- func() {
+			SetRenderWorley(selectedIndex == 5)
+		}
+		c <- func() {
+			SetRenderStainedGlass(selectedIndex == 6)
+		}
 		// We re-render everything no matter what happened after the user selected the radio button.
 		c <- func() {
 			ReadyForRender(true)
@@ -218,6 +488,56 @@ func createGeneralCheckboxes(c chan func()) *ui.Grid {
 	return grid
 }
 
+// How adaptive edges are colored.
+func createEdgeColorControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	source := ui.NewCombobox()
+	source.Append("Image Along Edge")
+	source.Append("Adjacent Cells")
+	blend := ui.NewCombobox()
+	blend.Append("Average")
+	blend.Append("Darker")
+	blend.Append("Gradient")
+
+	grid.Append(source, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(blend, 0, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	source.SetSelected(0)
+	blend.SetSelected(0)
+
+	source.OnSelected(func(*ui.Combobox) {
+		selectedIndex := source.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetEdgeColorSource(EDGE_COLOR_IMAGE)
+			case 1:
+				SetEdgeColorSource(EDGE_COLOR_CELLS)
+			}
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	blend.OnSelected(func(*ui.Combobox) {
+		selectedIndex := blend.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetEdgeBlend(EDGE_BLEND_AVERAGE)
+			case 1:
+				SetEdgeBlend(EDGE_BLEND_DARKER)
+			case 2:
+				SetEdgeBlend(EDGE_BLEND_GRADIENT)
+			}
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
 func createVoronoiColorButton(c chan func()) *ui.ColorButton {
 	b := ui.NewColorButton()
 	b.SetColor(vLineColor[0], vLineColor[1], vLineColor[2], vLineColor[3])
@@ -274,96 +594,1476 @@ func createCHColorButton(c chan func()) *ui.ColorButton {
 	return b
 }
 
-func setupUI() {
+func createGroutColorButton(c chan func()) *ui.ColorButton {
+	b := ui.NewColorButton()
+	b.SetColor(groutColor[0], groutColor[1], groutColor[2], groutColor[3])
 
-	mainwin := ui.NewWindow("Geometry Controls", 360, 500, true)
-	mainwin.SetMargined(true)
-	mainwin.OnClosing(func(*ui.Window) bool {
-		fmt.Printf("Close.\n")
-		ui.Quit()
-		return true
-	})
-	ui.OnShouldQuit(func() bool {
-		fmt.Printf("Destroy.\n")
-		mainwin.Destroy()
-		return true
+	b.OnChanged(func(*ui.ColorButton) {
+		c <- func() {
+			SetGroutColor(b.Color())
+			ReadyForRender(true)
+		}
 	})
 
-	grid := ui.NewGrid()
-	grid.SetPadded(true)
-
-	imageOpLable := ui.NewLabel("Image Operations")
-	imageOpGrid := createImageLoadSaveOperations(mainwin, functionChannel)
+	return b
+}
 
-	pointLable := ui.NewLabel("Point Count")
-	pointButtons := createPointCountButtons(functionChannel)
+func createLeadingColorButton(c chan func()) *ui.ColorButton {
+	b := ui.NewColorButton()
+	b.SetColor(leadingColor[0], leadingColor[1], leadingColor[2], leadingColor[3])
 
-	distLable := ui.NewLabel("Point Distribution")
-	distButton := createPointDistributionButtons(functionChannel)
+	b.OnChanged(func(*ui.ColorButton) {
+		c <- func() {
+			SetLeadingColor(b.Color())
+			ReadyForRender(true)
+		}
+	})
 
-	faceLable := ui.NewLabel("Face Rendering")
-	faceButton := createFaceRenderingButtons(functionChannel)
+	return b
+}
 
-	generalLable := ui.NewLabel("General")
-	generalBoxes := createGeneralCheckboxes(functionChannel)
+func createCellColorControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
 
-	dColorLable := ui.NewLabel("Voronoi Color")
-	dColorButton := createVoronoiColorButton(functionChannel)
+	mode := ui.NewCombobox()
+	mode.Append("Shader Preview (fast)")
+	mode.Append("Exact Mean")
+	mode.Append("Median")
+	mode.Append("Most Frequent")
+	mode.Append("Dominant (k-means)")
+	shading := ui.NewCombobox()
+	shading.Append("Flat")
+	shading.Append("Gouraud (Triangles)")
+	shading.Append("Linear Gradient")
+	space := ui.NewCombobox()
+	space.Append("Average in sRGB")
+	space.Append("Average in Linear Light")
+	space.Append("Average in CIELAB")
+	space.Append("Average in OKLab")
+
+	grid.Append(mode, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(shading, 0, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(space, 0, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	mode.SetSelected(0)
+	shading.SetSelected(0)
+	space.SetSelected(0)
+
+	mode.OnSelected(func(*ui.Combobox) {
+		selectedIndex := mode.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetCellColorMode(CELL_COLOR_PREVIEW)
+			case 1:
+				SetCellColorMode(CELL_COLOR_MEAN)
+			case 2:
+				SetCellColorMode(CELL_COLOR_MEDIAN)
+			case 3:
+				SetCellColorMode(CELL_COLOR_MOST_FREQUENT)
+			case 4:
+				SetCellColorMode(CELL_COLOR_DOMINANT)
+			}
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	shading.OnSelected(func(*ui.Combobox) {
+		selectedIndex := shading.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetFaceShading(SHADING_FLAT)
+			case 1:
+				SetFaceShading(SHADING_GOURAUD)
+			case 2:
+				SetFaceShading(SHADING_GRADIENT)
+			}
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	space.OnSelected(func(*ui.Combobox) {
+		selectedIndex := space.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetColorSpace(COLOR_SPACE_SRGB)
+			case 1:
+				SetColorSpace(COLOR_SPACE_LINEAR)
+			case 2:
+				SetColorSpace(COLOR_SPACE_CIELAB)
+			case 3:
+				SetColorSpace(COLOR_SPACE_OKLAB)
+			}
+			// The CPU colors depend on it as well.
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
 
-	vColorLable := ui.NewLabel("Delaunay Color")
-	vColorButton := createDelaunayColorButton(functionChannel)
+	return grid
+}
 
-	pColorLable := ui.NewLabel("Point Color")
-	pColorButton := createPointColorButton(functionChannel)
+func createQuantizeControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
 
-	chColorLable := ui.NewLabel("Convex Hull Color")
-	chColorButton := createCHColorButton(functionChannel)
+	method := ui.NewCombobox()
+	method.Append("Off")
+	method.Append("Median Cut")
+	method.Append("k-means")
+	method.Append("Palette Coloring Entry")
+	countLable := ui.NewLabel("Colors")
+	count := ui.NewSpinbox(2, 64)
+	formatLable := ui.NewLabel("Palette File")
+	format := ui.NewCombobox()
+	format.Append("GIMP (.gpl)")
+	format.Append("Adobe (.ase)")
+	format.Append("JSON (.json)")
+
+	grid.Append(method, 0, 0, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(countLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(count, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(formatLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(format, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	method.SetSelected(0)
+	count.SetValue(DEFAULT_QUANTIZE_COLORS)
+	format.SetSelected(0)
+
+	method.OnSelected(func(*ui.Combobox) {
+		selectedIndex := method.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetQuantizeMethod(QUANTIZE_NONE)
+			case 1:
+				SetQuantizeMethod(QUANTIZE_MEDIAN_CUT)
+			case 2:
+				SetQuantizeMethod(QUANTIZE_KMEANS)
+			case 3:
+				SetQuantizeMethod(QUANTIZE_USER_PALETTE)
+			}
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	count.OnChanged(func(*ui.Spinbox) {
+		value := count.Value()
+		c <- func() {
+			SetQuantizeColorCount(value)
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	})
+	format.OnSelected(func(*ui.Combobox) {
+		selectedIndex := format.Selected()
+		c <- func() {
+			SetPaletteFileExtension([]string{".gpl", ".ase", ".json"}[selectedIndex])
+		}
+	})
 
-	gridYPos := 0
-	grid.Append(imageOpLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
-	grid.Append(imageOpGrid, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
-	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
+	return grid
+}
 
-	grid.Append(pointLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(pointButtons, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
-	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
+func createColorAdjustmentControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
 
-	grid.Append(distLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(distButton, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
-	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
+	saturationLable := ui.NewLabel("Saturation")
+	saturation := ui.NewSlider(0, 200)
+	brightnessLable := ui.NewLabel("Brightness")
+	brightness := ui.NewSlider(-100, 100)
+	contrastLable := ui.NewLabel("Contrast")
+	contrast := ui.NewSlider(0, 200)
+	hueLable := ui.NewLabel("Hue Shift")
+	hue := ui.NewSlider(-180, 180)
+	posterizeLable := ui.NewLabel("Posterize Levels")
+	posterize := ui.NewSpinbox(0, 32)
+	jitterLable := ui.NewLabel("Random Jitter")
+	jitter := ui.NewSlider(0, 50)
+	seedLable := ui.NewLabel("Jitter Seed")
+	seed := ui.NewSpinbox(0, 9999)
+
+	grid.Append(saturationLable, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(saturation, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(brightnessLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(brightness, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(contrastLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(contrast, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(hueLable, 0, 3, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(hue, 1, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(posterizeLable, 0, 4, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(posterize, 1, 4, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(jitterLable, 0, 5, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(jitter, 1, 5, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(seedLable, 0, 6, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(seed, 1, 6, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	saturation.SetValue(DEFAULT_SATURATION)
+	brightness.SetValue(0)
+	contrast.SetValue(DEFAULT_CONTRAST)
+	hue.SetValue(0)
+	posterize.SetValue(0)
+	jitter.SetValue(0)
+	seed.SetValue(0)
+
+	saturation.OnChanged(func(*ui.Slider) {
+		value := saturation.Value()
+		c <- func() {
+			SetSaturation(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	brightness.OnChanged(func(*ui.Slider) {
+		value := brightness.Value()
+		c <- func() {
+			SetBrightness(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	contrast.OnChanged(func(*ui.Slider) {
+		value := contrast.Value()
+		c <- func() {
+			SetContrast(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	hue.OnChanged(func(*ui.Slider) {
+		value := hue.Value()
+		c <- func() {
+			SetHueShift(float32(value))
+			ReadyForRender(true)
+		}
+	})
+	posterize.OnChanged(func(*ui.Spinbox) {
+		value := posterize.Value()
+		c <- func() {
+			SetPosterizeLevels(value)
+			ReadyForRender(true)
+		}
+	})
+	jitter.OnChanged(func(*ui.Slider) {
+		value := jitter.Value()
+		c <- func() {
+			SetColorJitter(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	seed.OnChanged(func(*ui.Spinbox) {
+		value := seed.Value()
+		c <- func() {
+			SetColorJitterSeed(uint32(value))
+			ReadyForRender(true)
+		}
+	})
 
-	grid.Append(faceLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(faceButton, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
-	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
+	return grid
+}
 
-	grid.Append(generalLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(generalBoxes, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
-	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
-	gridYPos++
+func createReliefControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
 
-	grid.Append(dColorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(dColorButton, 1, gridYPos, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
-	gridYPos++
+	mode := ui.NewCombobox()
+	mode.Append("Flat (no Lighting)")
+	mode.Append("Luminance Height")
+	mode.Append("Random Tilt")
+	mode.Append("Pyramid")
+	heightLable := ui.NewLabel("Height")
+	height := ui.NewSlider(0, 50)
+	seedLable := ui.NewLabel("Tilt Seed")
+	seed := ui.NewSpinbox(0, 9999)
+	azimuthLable := ui.NewLabel("Light Direction")
+	azimuth := ui.NewSlider(0, 360)
+	elevationLable := ui.NewLabel("Light Elevation")
+	elevation := ui.NewSlider(5, 90)
+	ambientLable := ui.NewLabel("Ambient")
+	ambient := ui.NewSlider(0, 100)
+	specularLable := ui.NewLabel("Specular")
+	specular := ui.NewSlider(0, 100)
+	shininessLable := ui.NewLabel("Shininess")
+	shininess := ui.NewSpinbox(1, 128)
+
+	grid.Append(mode, 0, 0, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(heightLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(height, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(seedLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(seed, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(azimuthLable, 0, 3, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(azimuth, 1, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(elevationLable, 0, 4, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(elevation, 1, 4, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(ambientLable, 0, 5, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(ambient, 1, 5, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(specularLable, 0, 6, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(specular, 1, 6, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(shininessLable, 0, 7, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(shininess, 1, 7, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	mode.SetSelected(0)
+	height.SetValue(DEFAULT_RELIEF_HEIGHT)
+	seed.SetValue(0)
+	azimuth.SetValue(DEFAULT_LIGHT_AZIMUTH)
+	elevation.SetValue(DEFAULT_LIGHT_ELEVATION)
+	ambient.SetValue(DEFAULT_LIGHT_AMBIENT)
+	specular.SetValue(DEFAULT_LIGHT_SPECULAR)
+	shininess.SetValue(DEFAULT_LIGHT_SHININESS)
+
+	mode.OnSelected(func(*ui.Combobox) {
+		selectedIndex := mode.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetReliefMode(RELIEF_NONE)
+			case 1:
+				SetReliefMode(RELIEF_LUMINANCE)
+			case 2:
+				SetReliefMode(RELIEF_RANDOM)
+			case 3:
+				SetReliefMode(RELIEF_PYRAMID)
+			}
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	// The normals are part of the vertex data.
+	height.OnChanged(func(*ui.Slider) {
+		value := height.Value()
+		c <- func() {
+			SetReliefHeight(float64(value))
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	seed.OnChanged(func(*ui.Spinbox) {
+		value := seed.Value()
+		c <- func() {
+			SetReliefSeed(uint32(value))
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	azimuth.OnChanged(func(*ui.Slider) {
+		value := azimuth.Value()
+		c <- func() {
+			SetLightAzimuth(float32(value))
+			ReadyForRender(true)
+		}
+	})
+	elevation.OnChanged(func(*ui.Slider) {
+		value := elevation.Value()
+		c <- func() {
+			SetLightElevation(float32(value))
+			ReadyForRender(true)
+		}
+	})
+	ambient.OnChanged(func(*ui.Slider) {
+		value := ambient.Value()
+		c <- func() {
+			SetLightAmbient(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	specular.OnChanged(func(*ui.Slider) {
+		value := specular.Value()
+		c <- func() {
+			SetLightSpecular(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	shininess.OnChanged(func(*ui.Spinbox) {
+		value := shininess.Value()
+		c <- func() {
+			SetLightShininess(float32(value))
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createMosaicControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	gapLable := ui.NewLabel("Gap")
+	gap := ui.NewSlider(0, 30)
+	variationLable := ui.NewLabel("Gap Variation")
+	variation := ui.NewSlider(0, 100)
+	groutLable := ui.NewLabel("Grout Color")
+	grout := createGroutColorButton(c)
+	groutImage := ui.NewCheckbox("Image Grout")
+
+	grid.Append(gapLable, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(gap, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(variationLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(variation, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(groutLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(grout, 1, 2, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(groutImage, 1, 3, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+
+	gap.SetValue(DEFAULT_MOSAIC_GAP)
+	variation.SetValue(0)
+	groutImage.SetChecked(false)
+
+	gap.OnChanged(func(*ui.Slider) {
+		c <- func() {
+			SetMosaicGap(float64(gap.Value()))
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	variation.OnChanged(func(*ui.Slider) {
+		c <- func() {
+			SetMosaicGapVariation(float64(variation.Value()) / 100.0)
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	groutImage.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetGroutUseImage(groutImage.Checked())
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createStainedGlassControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	widthLable := ui.NewLabel("Leading Width")
+	width := ui.NewSlider(0, 20)
+	colorLable := ui.NewLabel("Leading Color")
+	color := createLeadingColorButton(c)
+	glowLable := ui.NewLabel("Light Transmission")
+	glow := ui.NewSlider(0, 60)
+	textureLable := ui.NewLabel("Glass Texture")
+	texture := ui.NewSlider(0, 30)
+	saturationLable := ui.NewLabel("Saturation Boost")
+	saturation := ui.NewSlider(100, 200)
+
+	grid.Append(widthLable, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(width, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(colorLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(color, 1, 1, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(glowLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(glow, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(textureLable, 0, 3, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(texture, 1, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(saturationLable, 0, 4, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(saturation, 1, 4, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	width.SetValue(DEFAULT_LEADING_WIDTH)
+	glow.SetValue(DEFAULT_GLASS_GLOW)
+	texture.SetValue(DEFAULT_GLASS_TEXTURE)
+	saturation.SetValue(DEFAULT_GLASS_SATURATION)
+
+	// The leading is geometry.
+	width.OnChanged(func(*ui.Slider) {
+		value := width.Value()
+		c <- func() {
+			SetLeadingWidth(float64(value))
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	glow.OnChanged(func(*ui.Slider) {
+		value := glow.Value()
+		c <- func() {
+			SetGlassGlow(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	texture.OnChanged(func(*ui.Slider) {
+		value := texture.Value()
+		c <- func() {
+			SetGlassTexture(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+	saturation.OnChanged(func(*ui.Slider) {
+		value := saturation.Value()
+		c <- func() {
+			SetGlassSaturation(float32(value) / 100.0)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createCellShapeControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	shape := ui.NewCombobox()
+	shape.Append("Sharp")
+	shape.Append("Rounded")
+	shape.Append("Smoothed")
+	radiusLable := ui.NewLabel("Corner Radius")
+	radius := ui.NewSlider(0, 50)
+	smoothLable := ui.NewLabel("Smoothing Steps")
+	smooth := ui.NewSpinbox(1, 6)
+
+	grid.Append(shape, 0, 0, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(radiusLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(radius, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(smoothLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(smooth, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	shape.SetSelected(0)
+	radius.SetValue(DEFAULT_CELL_RADIUS)
+	smooth.SetValue(DEFAULT_CELL_SMOOTH)
+
+	shape.OnSelected(func(*ui.Combobox) {
+		selectedIndex := shape.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetCellShape(CELL_SHAPE_SHARP)
+			case 1:
+				SetCellShape(CELL_SHAPE_ROUNDED)
+			case 2:
+				SetCellShape(CELL_SHAPE_SMOOTH)
+			}
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	radius.OnChanged(func(*ui.Slider) {
+		c <- func() {
+			SetCellCornerRadius(float64(radius.Value()))
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	smooth.OnChanged(func(*ui.Spinbox) {
+		c <- func() {
+			SetCellSmoothIterations(smooth.Value())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createPaletteControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	palette := ui.NewEntry()
+	method := ui.NewCombobox()
+	method.Append("Greedy")
+	method.Append("Four Color")
+	balanced := ui.NewCheckbox("Balanced Usage")
+
+	grid.Append(palette, 0, 0, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(method, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(balanced, 1, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+
+	palette.SetText(DEFAULT_PALETTE)
+	method.SetSelected(1)
+	balanced.SetChecked(false)
+
+	palette.OnChanged(func(*ui.Entry) {
+		p, err := ParsePalette(palette.Text())
+		// Just wait for the user to finish typing a valid palette.
+		if err != nil {
+			return
+		}
+		c <- func() {
+			SetCellPalette(p)
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	method.OnSelected(func(*ui.Combobox) {
+		selectedIndex := method.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetPaletteColoringMethod(GRAPH_COLORING_GREEDY)
+			case 1:
+				SetPaletteColoringMethod(GRAPH_COLORING_FOUR)
+			}
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	balanced.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetPaletteBalanced(balanced.Checked())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createMetricControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	metric := ui.NewCombobox()
+	metric.Append("Manhattan (L1)")
+	metric.Append("Chebyshev (L∞)")
+	metric.Append("Minkowski (Lp)")
+	exponentLable := ui.NewLabel("Exponent p")
+	exponent := ui.NewSlider(10, 100)
+
+	grid.Append(metric, 0, 0, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(exponentLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(exponent, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	metric.SetSelected(0)
+	exponent.SetValue(DEFAULT_MINKOWSKI_EXPONENT)
+
+	metric.OnSelected(func(*ui.Combobox) {
+		selectedIndex := metric.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetVoronoiMetric(METRIC_MANHATTAN)
+			case 1:
+				SetVoronoiMetric(METRIC_CHEBYSHEV)
+			case 2:
+				SetVoronoiMetric(METRIC_MINKOWSKI)
+			}
+			ReadyForRender(true)
+		}
+	})
+	exponent.OnChanged(func(*ui.Slider) {
+		c <- func() {
+			SetMinkowskiExponent(float64(exponent.Value()) / 10.0)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createWorleyControls(mainwin *ui.Window, c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	mode := ui.NewCombobox()
+	mode.Append("F1")
+	mode.Append("F2")
+	mode.Append("F2 - F1")
+	mode.Append("Crackle")
+	mode.Append("Scales")
+	invert := ui.NewCheckbox("Invert")
+	modulate := ui.NewCheckbox("Image Colors")
+	export := ui.NewButton("Export Noise")
+
+	grid.Append(mode, 0, 0, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(invert, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(modulate, 1, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(export, 0, 2, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+
+	mode.SetSelected(0)
+	invert.SetChecked(false)
+	modulate.SetChecked(false)
+
+	mode.OnSelected(func(*ui.Combobox) {
+		selectedIndex := mode.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetWorleyMode(WORLEY_F1)
+			case 1:
+				SetWorleyMode(WORLEY_F2)
+			case 2:
+				SetWorleyMode(WORLEY_F2_MINUS_F1)
+			case 3:
+				SetWorleyMode(WORLEY_CRACKLE)
+			case 4:
+				SetWorleyMode(WORLEY_SCALES)
+			}
+			ReadyForRender(true)
+		}
+	})
+	invert.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetWorleyInvert(invert.Checked())
+			ReadyForRender(true)
+		}
+	})
+	modulate.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetWorleyModulate(modulate.Checked())
+			ReadyForRender(true)
+		}
+	})
+	export.OnClicked(func(*ui.Button) {
+		filename := ui.SaveFile(mainwin)
+		if filename != "" {
+
+			if !strings.HasSuffix(filename, ".png") {
+				filename = filename + ".png"
+			}
+
+			c <- func() {
+				ExportWorleyImage(filename)
+			}
+		}
+	})
+
+	return grid
+}
+
+// Everything that changes the shape or look of the single cells.
+func setupCellsPage(mainwin *ui.Window, c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	colorLable := ui.NewLabel("Cell Color")
+	colorControls := createCellColorControls(c)
+
+	quantizeLable := ui.NewLabel("Quantize Colors")
+	quantizeControls := createQuantizeControls(c)
+
+	adjustLable := ui.NewLabel("Adjust Colors")
+	adjustControls := createColorAdjustmentControls(c)
+
+	reliefLable := ui.NewLabel("Relief Lighting")
+	reliefControls := createReliefControls(c)
+
+	mosaicLable := ui.NewLabel("Mosaic Tiles")
+	mosaicControls := createMosaicControls(c)
+
+	glassLable := ui.NewLabel("Stained Glass")
+	glassControls := createStainedGlassControls(c)
+
+	shapeLable := ui.NewLabel("Cell Shape")
+	shapeControls := createCellShapeControls(c)
+
+	paletteLable := ui.NewLabel("Palette Coloring")
+	paletteControls := createPaletteControls(c)
+
+	metricLable := ui.NewLabel("Metric Voronoi")
+	metricControls := createMetricControls(c)
+
+	worleyLable := ui.NewLabel("Worley Noise")
+	worleyControls := createWorleyControls(mainwin, c)
+
+	gridYPos := 0
+	grid.Append(colorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(colorControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(quantizeLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(quantizeControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(adjustLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(adjustControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(reliefLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(reliefControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(mosaicLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(mosaicControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(glassLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(glassControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(shapeLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(shapeControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(paletteLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(paletteControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(metricLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(metricControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(worleyLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(worleyControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	return grid
+}
+
+func createAlphaShapeColorButton(c chan func()) *ui.ColorButton {
+	b := ui.NewColorButton()
+	b.SetColor(alphaShapeColor[0], alphaShapeColor[1], alphaShapeColor[2], alphaShapeColor[3])
+
+	b.OnChanged(func(*ui.ColorButton) {
+		c <- func() {
+			SetAlphaShapeColor(b.Color())
+			ReadyForRender(true)
+		}
+	})
+
+	return b
+}
+
+func createAlphaShapeControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	show := ui.NewCheckbox("Show")
+	fill := ui.NewCheckbox("Fill")
+	radiusLable := ui.NewLabel("Alpha Radius")
+	radius := ui.NewSlider(50, 1000)
+	color := createAlphaShapeColorButton(c)
+	widthLable := ui.NewLabel("Line Width")
+	width := createLineWidthSlider(c, SetAlphaShapeLineWidth)
+
+	grid.Append(show, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(fill, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(radiusLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(radius, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(color, 1, 2, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(widthLable, 0, 3, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(width, 1, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	show.SetChecked(false)
+	fill.SetChecked(false)
+	radius.SetValue(DEFAULT_ALPHA_RADIUS)
+
+	show.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetRenderAlphaShape(show.Checked())
+			ReadyForRender(true)
+		}
+	})
+	fill.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetRenderAlphaShapeFill(fill.Checked())
+			ReadyForRender(true)
+		}
+	})
+	radius.OnChanged(func(*ui.Slider) {
+		c <- func() {
+			SetAlphaShapeFactor(float64(radius.Value()) / 100.0)
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createBackgroundControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	background := ui.NewCombobox()
+	background.Append("Transparent")
+	background.Append("Color")
+	background.Append("Checkerboard")
+	background.Append("Original Image")
+	colorButton := createLayerColorButton(c, backgroundColor, SetBackgroundColor)
+
+	grid.Append(background, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(colorButton, 1, 0, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+
+	background.SetSelected(0)
+
+	background.OnSelected(func(*ui.Combobox) {
+		selectedIndex := background.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetBackground(BACKGROUND_TRANSPARENT)
+			case 1:
+				SetBackground(BACKGROUND_COLOR)
+			case 2:
+				SetBackground(BACKGROUND_CHECKERBOARD)
+			case 3:
+				SetBackground(BACKGROUND_IMAGE)
+			}
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+// Samples per pixel for the entries of the anti-aliasing combobox.
+var multisampleCounts = []int{1, 2, 4, 8, 16}
+
+func createAntiAliasingControls(c chan func()) *ui.Combobox {
+	samples := ui.NewCombobox()
+	for i, count := range multisampleCounts {
+		if count < 2 {
+			samples.Append("Off")
+		} else {
+			samples.Append(fmt.Sprintf("%dx Multisampling", count))
+		}
+		if count == DEFAULT_MULTISAMPLE_COUNT {
+			samples.SetSelected(i)
+		}
+	}
+
+	samples.OnSelected(func(*ui.Combobox) {
+		count := multisampleCounts[samples.Selected()]
+		c <- func() {
+			SetMultisampleCount(count)
+			ReadyForRender(true)
+		}
+	})
+
+	return samples
+}
+
+// Width of one line layer, set is the matching SetXLineWidth function.
+func createLineWidthSlider(c chan func(), set func(width float32)) *ui.Slider {
+	s := ui.NewSlider(5, 200)
+	s.SetValue(DEFAULT_LINE_WIDTH)
+
+	s.OnChanged(func(*ui.Slider) {
+		value := s.Value()
+		c <- func() {
+			set(float32(value) / 10.0)
+			ReadyForRender(true)
+		}
+	})
+
+	return s
+}
+
+// Color button and width slider of a line layer next to each other.
+func createLineLayerRow(color *ui.ColorButton, width *ui.Slider) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	grid.Append(color, 0, 0, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(width, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	return grid
+}
+
+func createLineJoinControls(c chan func()) *ui.Combobox {
+	join := ui.NewCombobox()
+	join.Append("Round")
+	join.Append("Miter")
+	join.SetSelected(0)
+
+	join.OnSelected(func(*ui.Combobox) {
+		selectedIndex := join.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetLineJoin(LINE_JOIN_ROUND)
+			case 1:
+				SetLineJoin(LINE_JOIN_MITER)
+			}
+			ReadyForRender(true)
+		}
+	})
+
+	return join
+}
+
+// The color buttons of all line layers look the same. set is the matching SetXColor function.
+func createLayerColorButton(c chan func(), color [4]float64, set func(r, g, b, a float64)) *ui.ColorButton {
+	b := ui.NewColorButton()
+	b.SetColor(color[0], color[1], color[2], color[3])
+
+	b.OnChanged(func(*ui.ColorButton) {
+		c <- func() {
+			set(b.Color())
+			ReadyForRender(true)
+		}
+	})
+
+	return b
+}
+
+func createProximityGraphControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	mst := ui.NewCheckbox("Minimum Spanning Tree")
+	gabriel := ui.NewCheckbox("Gabriel Graph")
+	rng := ui.NewCheckbox("Relative Neighborhood")
+	knn := ui.NewCheckbox("k Nearest Neighbors")
+	k := ui.NewSpinbox(1, 12)
+
+	grid.Append(mst, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(createLayerColorButton(c, mstColor, SetMSTColor), 1, 0, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(createLineWidthSlider(c, SetMSTLineWidth), 2, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(gabriel, 0, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(createLayerColorButton(c, gabrielColor, SetGabrielColor), 1, 1, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(createLineWidthSlider(c, SetGabrielLineWidth), 2, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(rng, 0, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(createLayerColorButton(c, rngColor, SetRNGColor), 1, 2, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(createLineWidthSlider(c, SetRNGLineWidth), 2, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(knn, 0, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(createLayerColorButton(c, knnColor, SetKNNColor), 1, 3, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(createLineWidthSlider(c, SetKNNLineWidth), 2, 3, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(k, 0, 4, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+
+	k.SetValue(DEFAULT_KNN_COUNT)
+
+	mst.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetRenderMST(mst.Checked())
+			ReadyForRender(true)
+		}
+	})
+	gabriel.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetRenderGabriel(gabriel.Checked())
+			ReadyForRender(true)
+		}
+	})
+	rng.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetRenderRNG(rng.Checked())
+			ReadyForRender(true)
+		}
+	})
+	knn.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetRenderKNN(knn.Checked())
+			ReadyForRender(true)
+		}
+	})
+	k.OnChanged(func(*ui.Spinbox) {
+		c <- func() {
+			SetKNNCount(k.Value())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+// Additional lines and shapes that are derived from the triangulation and drawn on top.
+func setupOverlaysPage(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	alphaLable := ui.NewLabel("Alpha Shape")
+	alphaControls := createAlphaShapeControls(c)
+
+	proximityLable := ui.NewLabel("Proximity Graphs")
+	proximityControls := createProximityGraphControls(c)
+
+	gridYPos := 0
+	grid.Append(alphaLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(alphaControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(proximityLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(proximityControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	return grid
+}
+
+func createPointStyleControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	sizeLable := ui.NewLabel("Size")
+	size := ui.NewSlider(1, 64)
+	shapeLable := ui.NewLabel("Shape")
+	shape := ui.NewCombobox()
+	shape.Append("Circle")
+	shape.Append("Square")
+	shape.Append("Diamond")
+	shape.Append("Ring")
+	shape.Append("Hexagon")
+	sizeModeLable := ui.NewLabel("Scale By")
+	sizeMode := ui.NewCombobox()
+	sizeMode.Append("Nothing")
+	sizeMode.Append("Image Darkness")
+	sizeMode.Append("Cell Area")
+
+	grid.Append(sizeLable, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(size, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(shapeLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(shape, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(sizeModeLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(sizeMode, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	size.SetValue(DEFAULT_POINT_SIZE)
+	shape.SetSelected(0)
+	sizeMode.SetSelected(0)
+
+	size.OnChanged(func(*ui.Slider) {
+		value := size.Value()
+		c <- func() {
+			SetPointSize(float32(value))
+			ReadyForRender(true)
+		}
+	})
+	shape.OnSelected(func(*ui.Combobox) {
+		selectedIndex := shape.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetPointShape(POINT_SHAPE_CIRCLE)
+			case 1:
+				SetPointShape(POINT_SHAPE_SQUARE)
+			case 2:
+				SetPointShape(POINT_SHAPE_DIAMOND)
+			case 3:
+				SetPointShape(POINT_SHAPE_RING)
+			case 4:
+				SetPointShape(POINT_SHAPE_HEXAGON)
+			}
+			ReadyForRender(true)
+		}
+	})
+	// The sizes of the single points are calculated on the CPU.
+	sizeMode.OnSelected(func(*ui.Combobox) {
+		selectedIndex := sizeMode.Selected()
+		c <- func() {
+			switch selectedIndex {
+			case 0:
+				SetPointSizeMode(POINT_SIZE_FIXED)
+			case 1:
+				SetPointSizeMode(POINT_SIZE_DARKNESS)
+			case 2:
+				SetPointSizeMode(POINT_SIZE_CELL_AREA)
+			}
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createAdaptiveControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	thresholdLable := ui.NewLabel("Max Color Deviation")
+	threshold := ui.NewSlider(1, 50)
+	depthLable := ui.NewLabel("Max Depth")
+	depth := ui.NewSpinbox(1, 8)
+	budgetLable := ui.NewLabel("Point Budget")
+	budget := ui.NewSpinbox(10, 100000)
+	progress := ui.NewProgressBar()
+	progressLable := ui.NewLabel("")
+
+	grid.Append(thresholdLable, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(threshold, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(depthLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(depth, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(budgetLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(budget, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(progress, 0, 3, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(progressLable, 0, 4, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	threshold.SetValue(DEFAULT_ADAPTIVE_THRESHOLD)
+	depth.SetValue(DEFAULT_ADAPTIVE_DEPTH)
+	budget.SetValue(DEFAULT_ADAPTIVE_BUDGET)
+	progress.SetValue(0)
+
+	// The refinement runs in the render thread. The ui may only be changed from the ui thread.
+	c <- func() {
+		SetAdaptiveProgressCallback(func(level, maxDepth, pointCount int) {
+			ui.QueueMain(func() {
+				progress.SetValue(level * 100 / maxDepth)
+				progressLable.SetText(fmt.Sprintf("Level %d of %d: %d points", level, maxDepth, pointCount))
+			})
+		})
+	}
+
+	threshold.OnChanged(func(*ui.Slider) {
+		c <- func() {
+			SetAdaptiveThreshold(float64(threshold.Value()) / 100.0)
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	depth.OnChanged(func(*ui.Spinbox) {
+		c <- func() {
+			SetAdaptiveMaxDepth(depth.Value())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	budget.OnChanged(func(*ui.Spinbox) {
+		c <- func() {
+			SetAdaptivePointBudget(budget.Value())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+func createOptimizerControls(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	optimize := ui.NewCheckbox("Optimize Triangles")
+	stepsLable := ui.NewLabel("Max Steps")
+	steps := ui.NewSpinbox(100, 100000)
+	timeLable := ui.NewLabel("Max Seconds")
+	seconds := ui.NewSpinbox(1, 300)
+	progress := ui.NewProgressBar()
+	progressLable := ui.NewLabel("")
+
+	grid.Append(optimize, 0, 0, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(stepsLable, 0, 1, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(steps, 1, 1, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(timeLable, 0, 2, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(seconds, 1, 2, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(progress, 0, 3, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(progressLable, 0, 4, 2, 1, true, ui.AlignFill, false, ui.AlignFill)
+
+	optimize.SetChecked(false)
+	steps.SetValue(DEFAULT_OPTIMIZER_STEPS)
+	seconds.SetValue(DEFAULT_OPTIMIZER_TIME)
+	progress.SetValue(0)
+
+	// The optimizer runs in its own goroutine. The ui may only be changed from the ui thread.
+	c <- func() {
+		SetOptimizerProgressCallback(func(step int, done float64) {
+			ui.QueueMain(func() {
+				progress.SetValue(int(done * 100))
+				progressLable.SetText(fmt.Sprintf("Step %d", step))
+			})
+		})
+	}
+
+	optimize.OnToggled(func(*ui.Checkbox) {
+		c <- func() {
+			SetOptimizeTriangles(optimize.Checked())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	steps.OnChanged(func(*ui.Spinbox) {
+		c <- func() {
+			SetOptimizerIterations(steps.Value())
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+	seconds.OnChanged(func(*ui.Spinbox) {
+		c <- func() {
+			SetOptimizerTimeBudget(time.Duration(seconds.Value()) * time.Second)
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	})
+
+	return grid
+}
+
+// Settings of the point distributions that need more than a radio button.
+func setupPointsPage(c chan func()) *ui.Grid {
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	pointStyleLable := ui.NewLabel("Point Rendering")
+	pointStyleControls := createPointStyleControls(c)
+
+	adaptiveLable := ui.NewLabel("Adaptive Refinement")
+	adaptiveControls := createAdaptiveControls(c)
+
+	optimizerLable := ui.NewLabel("Triangle Optimizer")
+	optimizerControls := createOptimizerControls(c)
+
+	gridYPos := 0
+	grid.Append(pointStyleLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(pointStyleControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(adaptiveLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(adaptiveControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(optimizerLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(optimizerControls, 1, gridYPos, 1, 1, true, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	return grid
+}
+
+func setupUI() {
+
+	mainwin := ui.NewWindow("Geometry Controls", 360, 500, true)
+	mainwin.SetMargined(true)
+	mainwin.OnClosing(func(*ui.Window) bool {
+		fmt.Printf("Close.\n")
+		ui.Quit()
+		return true
+	})
+	ui.OnShouldQuit(func() bool {
+		fmt.Printf("Destroy.\n")
+		mainwin.Destroy()
+		return true
+	})
+
+	grid := ui.NewGrid()
+	grid.SetPadded(true)
+
+	imageOpLable := ui.NewLabel("Image Operations")
+	imageOpGrid := createImageLoadSaveOperations(mainwin, functionChannel)
+
+	exportLable := ui.NewLabel("Full Resolution Export")
+	exportControls := createExportControls(mainwin, functionChannel)
+
+	colorImageLable := ui.NewLabel("Color Image")
+	colorImageControls := createColorImageControls(mainwin, functionChannel)
+
+	backgroundLable := ui.NewLabel("Background")
+	backgroundControls := createBackgroundControls(functionChannel)
+
+	antiAliasingLable := ui.NewLabel("Anti-Aliasing")
+	antiAliasing := createAntiAliasingControls(functionChannel)
+
+	pointLable := ui.NewLabel("Point Count")
+	pointButtons := createPointCountButtons(functionChannel)
+
+	distLable := ui.NewLabel("Point Distribution")
+	distButton := createPointDistributionButtons(functionChannel)
+
+	faceLable := ui.NewLabel("Face Rendering")
+	faceButton := createFaceRenderingButtons(functionChannel)
+
+	generalLable := ui.NewLabel("General")
+	generalBoxes := createGeneralCheckboxes(functionChannel)
+
+	edgeColorLable := ui.NewLabel("Adaptive Edge Color")
+	edgeColorControls := createEdgeColorControls(functionChannel)
+
+	dColorLable := ui.NewLabel("Voronoi Color")
+	dColorButton := createVoronoiColorButton(functionChannel)
+
+	vColorLable := ui.NewLabel("Delaunay Color")
+	vColorButton := createDelaunayColorButton(functionChannel)
+
+	pColorLable := ui.NewLabel("Point Color")
+	pColorButton := createPointColorButton(functionChannel)
+
+	chColorLable := ui.NewLabel("Convex Hull Color")
+	chColorButton := createCHColorButton(functionChannel)
+
+	lineJoinLable := ui.NewLabel("Line Joins")
+	lineJoin := createLineJoinControls(functionChannel)
+
+	gridYPos := 0
+	grid.Append(imageOpLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(imageOpGrid, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(exportLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(exportControls, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(colorImageLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(colorImageControls, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(backgroundLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(backgroundControls, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(antiAliasingLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(antiAliasing, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(pointLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(pointButtons, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(distLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(distButton, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(faceLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(faceButton, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(generalLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(generalBoxes, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(edgeColorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignStart)
+	grid.Append(edgeColorControls, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(ui.NewHorizontalSeparator(), 0, gridYPos, 2, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+
+	grid.Append(dColorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(createLineLayerRow(dColorButton, createLineWidthSlider(functionChannel, SetVoronoiLineWidth)), 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
 	grid.Append(vColorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(vColorButton, 1, gridYPos, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(createLineLayerRow(vColorButton, createLineWidthSlider(functionChannel, SetDelaunayLineWidth)), 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
 	gridYPos++
 	grid.Append(pColorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
 	grid.Append(pColorButton, 1, gridYPos, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
 	gridYPos++
 	grid.Append(chColorLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
-	grid.Append(chColorButton, 1, gridYPos, 1, 1, false, ui.AlignStart, false, ui.AlignFill)
+	grid.Append(createLineLayerRow(chColorButton, createLineWidthSlider(functionChannel, SetCHLineWidth)), 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	gridYPos++
+	grid.Append(lineJoinLable, 0, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
+	grid.Append(lineJoin, 1, gridYPos, 1, 1, false, ui.AlignFill, false, ui.AlignFill)
 	gridYPos++
 
-	mainwin.SetChild(grid)
+	tab := ui.NewTab()
+	tab.Append("General", grid)
+	tab.SetMargined(0, true)
+	tab.Append("Points", setupPointsPage(functionChannel))
+	tab.SetMargined(1, true)
+	tab.Append("Cells", setupCellsPage(mainwin, functionChannel))
+	tab.SetMargined(2, true)
+	tab.Append("Overlays", setupOverlaysPage(functionChannel))
+	tab.SetMargined(3, true)
+
+	mainwin.SetChild(tab)
 
 	mainwin.Show()
 
@@ -376,21 +2076,123 @@ func setDefaultRenderValues(c chan func()) {
 	// That means, it blocks until the main rendering thread is initialized and is able to pull from the channel.
 	// This is OK because we are in the initialization phase anyway.
 	c <- func() {
+		SetColorImage("")
+		SetColorImageFit(COLOR_FIT_STRETCH)
+		SetColorImageScale(DEFAULT_COLOR_IMAGE_SCALE / 100.0)
+		SetColorImageOffsetX(0)
+		SetColorImageOffsetY(0)
+		SetBackground(BACKGROUND_TRANSPARENT)
+		SetBackgroundColor(backgroundColor[0], backgroundColor[1], backgroundColor[2], backgroundColor[3])
+		SetMultisampleCount(DEFAULT_MULTISAMPLE_COUNT)
+		SetExportWidth(0)
+		SetExportDPI(DEFAULT_EXPORT_DPI)
+
 		SetPointDistributionMethod(POINT_DISTRIBUTION_POISSON)
+		SetAdaptiveThreshold(DEFAULT_ADAPTIVE_THRESHOLD / 100.0)
+		SetAdaptiveMaxDepth(DEFAULT_ADAPTIVE_DEPTH)
+		SetAdaptivePointBudget(DEFAULT_ADAPTIVE_BUDGET)
+		SetOptimizeTriangles(false)
+		SetOptimizerIterations(DEFAULT_OPTIMIZER_STEPS)
+		SetOptimizerTimeBudget(DEFAULT_OPTIMIZER_TIME * time.Second)
 
 		SetRenderTriangles(false)
 		SetRenderVoronoiCells(true)
+		SetRenderMosaic(false)
+		SetRenderPaletteCells(false)
+		SetRenderMetricVoronoi(false)
+		SetRenderWorley(false)
 
 		SetRenderVoronoiEdges(false)
 		SetRenderLines(false)
 		SetRenderPoints(false)
 		SetRenderConvexHull(false)
 		SetUseExternalColor(true)
+		SetEdgeColorSource(EDGE_COLOR_IMAGE)
+		SetEdgeBlend(EDGE_BLEND_AVERAGE)
 
 		SetVoronoiLineColor(vLineColor[0], vLineColor[1], vLineColor[2], vLineColor[3])
 		SetDelaunayLineColor(dLineColor[0], dLineColor[1], dLineColor[2], dLineColor[3])
 		SetPointColor(pointColor[0], pointColor[1], pointColor[2], pointColor[3])
+		SetPointSize(DEFAULT_POINT_SIZE)
+		SetPointShape(POINT_SHAPE_CIRCLE)
+		SetPointSizeMode(POINT_SIZE_FIXED)
 		SetCHColor(chColor[0], chColor[1], chColor[2], chColor[3])
+		SetVoronoiLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+		SetDelaunayLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+		SetCHLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+		SetLineJoin(LINE_JOIN_ROUND)
+
+		SetCellColorMode(CELL_COLOR_PREVIEW)
+		SetFaceShading(SHADING_FLAT)
+		SetColorSpace(COLOR_SPACE_SRGB)
+		SetQuantizeMethod(QUANTIZE_NONE)
+		SetQuantizeColorCount(DEFAULT_QUANTIZE_COLORS)
+		SetPaletteFileExtension(".gpl")
+
+		SetSaturation(DEFAULT_SATURATION / 100.0)
+		SetBrightness(0)
+		SetContrast(DEFAULT_CONTRAST / 100.0)
+		SetHueShift(0)
+		SetPosterizeLevels(0)
+		SetColorJitter(0)
+		SetColorJitterSeed(0)
+
+		SetReliefMode(RELIEF_NONE)
+		SetReliefHeight(DEFAULT_RELIEF_HEIGHT)
+		SetReliefSeed(0)
+		SetLightAzimuth(DEFAULT_LIGHT_AZIMUTH)
+		SetLightElevation(DEFAULT_LIGHT_ELEVATION)
+		SetLightAmbient(DEFAULT_LIGHT_AMBIENT / 100.0)
+		SetLightSpecular(DEFAULT_LIGHT_SPECULAR / 100.0)
+		SetLightShininess(DEFAULT_LIGHT_SHININESS)
+
+		SetMosaicGap(DEFAULT_MOSAIC_GAP)
+		SetMosaicGapVariation(0)
+		SetGroutColor(groutColor[0], groutColor[1], groutColor[2], groutColor[3])
+		SetGroutUseImage(false)
+
+		SetRenderStainedGlass(false)
+		SetLeadingWidth(DEFAULT_LEADING_WIDTH)
+		SetLeadingColor(leadingColor[0], leadingColor[1], leadingColor[2], leadingColor[3])
+		SetGlassGlow(DEFAULT_GLASS_GLOW / 100.0)
+		SetGlassTexture(DEFAULT_GLASS_TEXTURE / 100.0)
+		SetGlassSaturation(DEFAULT_GLASS_SATURATION / 100.0)
+
+		SetCellShape(CELL_SHAPE_SHARP)
+		SetCellCornerRadius(DEFAULT_CELL_RADIUS)
+		SetCellSmoothIterations(DEFAULT_CELL_SMOOTH)
+
+		palette, _ := ParsePalette(DEFAULT_PALETTE)
+		SetCellPalette(palette)
+		SetPaletteColoringMethod(GRAPH_COLORING_FOUR)
+		SetPaletteBalanced(false)
+
+		SetRenderAlphaShape(false)
+		SetRenderAlphaShapeFill(false)
+		SetAlphaShapeFactor(DEFAULT_ALPHA_RADIUS / 100.0)
+		SetAlphaShapeColor(alphaShapeColor[0], alphaShapeColor[1], alphaShapeColor[2], alphaShapeColor[3])
+		SetAlphaShapeLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+
+		SetRenderMST(false)
+		SetRenderGabriel(false)
+		SetRenderRNG(false)
+		SetRenderKNN(false)
+		SetKNNCount(DEFAULT_KNN_COUNT)
+		SetMSTColor(mstColor[0], mstColor[1], mstColor[2], mstColor[3])
+		SetGabrielColor(gabrielColor[0], gabrielColor[1], gabrielColor[2], gabrielColor[3])
+		SetRNGColor(rngColor[0], rngColor[1], rngColor[2], rngColor[3])
+		SetKNNColor(knnColor[0], knnColor[1], knnColor[2], knnColor[3])
+		SetMSTLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+		SetGabrielLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+		SetRNGLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+		SetKNNLineWidth(DEFAULT_LINE_WIDTH / 10.0)
+
+		SetVoronoiMetric(METRIC_MANHATTAN)
+		SetMinkowskiExponent(DEFAULT_MINKOWSKI_EXPONENT / 10.0)
+
+		SetWorleyMode(WORLEY_F1)
+		SetWorleyInvert(false)
+		SetWorleyModulate(false)
 
 		ReadyForRebuild(true)
 		ReadyForRender(true)
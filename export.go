@@ -0,0 +1,154 @@
+// export
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Larger tiles need a lot of memory with multisampling.
+const g_maxExportTileSize = 4096
+
+// Framebuffer with plain color and depth renderbuffers. The multisample tiles are resolved into it and read back.
+// Without multisampling, the tiles are rendered into it directly.
+func createColorTarget(width, height int32) MultisampleTarget {
+	t := MultisampleTarget{Width: width, Height: height, Samples: 1}
+
+	gl.GenRenderbuffers(1, &t.ColorBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.ColorBuffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.RGBA8, width, height)
+	gl.GenRenderbuffers(1, &t.DepthBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.DepthBuffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, width, height)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+	gl.GenFramebuffers(1, &t.Fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.Fbo)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, t.ColorBuffer)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.DepthBuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	return t
+}
+
+// Largest tile the driver can render and the bytes we are willing to spend.
+func maxTileSize() int32 {
+	size := int32(g_maxExportTileSize)
+
+	var renderbufferSize int32
+	gl.GetIntegerv(gl.MAX_RENDERBUFFER_SIZE, &renderbufferSize)
+	var viewportSize [2]int32
+	gl.GetIntegerv(gl.MAX_VIEWPORT_DIMS, &viewportSize[0])
+
+	for _, s := range []int32{renderbufferSize, viewportSize[0], viewportSize[1]} {
+		if s > 0 && s < size {
+			size = s
+		}
+	}
+	return size
+}
+
+// Renders the current tessellation offscreen at width x height pixels. The picture is the same as in the window,
+// only at a higher (or lower) resolution. Images larger than the GL limits are rendered in tiles.
+func renderOffscreen(width, height int) *image.RGBA {
+	scale := float32(width) / float32(g_windowWidth)
+
+	// Points and lines are quads, so they continue seamlessly in the next tile.
+	tileSize := maxTileSize()
+
+	target := createColorTarget(tileSize, tileSize)
+	defer target.delete()
+	multisample := createMultisampleTarget(tileSize, tileSize, g_multisampleCount)
+	defer multisample.delete()
+	renderFbo := target.Fbo
+	if multisample.Fbo != 0 {
+		renderFbo = multisample.Fbo
+	}
+
+	g_exportingImage = true
+	defer func() { g_exportingImage = false }()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	tile := make([]byte, tileSize*tileSize*4)
+
+	for tileY := int32(0); tileY < int32(height); tileY += tileSize {
+		for tileX := int32(0); tileX < int32(width); tileX += tileSize {
+			w := int32(math.Min(float64(tileSize), float64(int32(width)-tileX)))
+			h := int32(math.Min(float64(tileSize), float64(int32(height)-tileY)))
+
+			// tileX/tileY are in pixels from the lower left corner, like the window coordinates.
+			view := RenderView{
+				X:           float32(tileX) / scale,
+				Y:           float32(tileY) / scale,
+				Width:       float32(w) / scale,
+				Height:      float32(h) / scale,
+				PixelWidth:  w,
+				PixelHeight: h,
+			}
+			renderDelaunayView(view, renderFbo)
+			multisample.resolve(target.Fbo)
+
+			gl.BindFramebuffer(gl.READ_FRAMEBUFFER, target.Fbo)
+			gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+			gl.ReadPixels(0, 0, w, h, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(tile))
+			gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+			// OpenGL rows go up, image rows go down.
+			for row := int32(0); row < h; row++ {
+				y := int32(height) - 1 - (tileY + row)
+				i := img.PixOffset(int(tileX), int(y))
+				copy(img.Pix[i:i+int(w)*4], tile[row*w*4:(row+1)*w*4])
+			}
+		}
+	}
+
+	return img
+}
+
+// Encodes img as PNG with a pHYs chunk, so other programs know the print size. A dpi of 0 writes no chunk.
+func encodePNGWithDPI(img image.Image, dpi int) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	data := buffer.Bytes()
+	if dpi <= 0 {
+		return data, nil
+	}
+
+	// Pixels per meter for both axes and the unit (1 = meter).
+	chunk := make([]byte, 9)
+	pixelsPerMeter := uint32(math.Round(float64(dpi) / 0.0254))
+	binary.BigEndian.PutUint32(chunk[0:4], pixelsPerMeter)
+	binary.BigEndian.PutUint32(chunk[4:8], pixelsPerMeter)
+	chunk[8] = 1
+
+	var phys bytes.Buffer
+	binary.Write(&phys, binary.BigEndian, uint32(len(chunk)))
+	phys.WriteString("pHYs")
+	phys.Write(chunk)
+	binary.Write(&phys, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("pHYs"), chunk...)))
+
+	// Signature (8 bytes) and the IHDR chunk (25 bytes) must come first.
+	const headerLength = 8 + 25
+	result := make([]byte, 0, len(data)+phys.Len())
+	result = append(result, data[:headerLength]...)
+	result = append(result, phys.Bytes()...)
+	result = append(result, data[headerLength:]...)
+	return result, nil
+}
+
+func writePNGWithDPI(path string, img image.Image, dpi int) error {
+	data, err := encodePNGWithDPI(img, dpi)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -5,7 +5,9 @@ import (
 	"image"
 	"image/png"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	geo "github.com/MauriceGit/mtGeometry"
@@ -25,12 +27,64 @@ const (
 	g_windowTitle    = "Delaunay/Voronoi Image Manipulation"
 	g_delaunayMargin = 10.0
 	g_maxWindowSize  = 1000
+	// Mosaic tiles will never be inset by more than this fraction of the distance from site to closest edge.
+	g_mosaicMaxInsetRatio = 0.5
+	// How many line segments approximate one rounded cell corner.
+	g_cellRoundSegments = 6
+	// How many triangles approximate the round join of the stained glass leading at every corner.
+	g_leadingJoinSegments = 8
+	// Sites are stored row by row in a texture of this width for the Worley shader.
+	g_siteTextureWidth = 1024
 )
 
 const (
 	POINT_DISTRIBUTION_RANDOM  = iota
 	POINT_DISTRIBUTION_GRID    = iota
 	POINT_DISTRIBUTION_POISSON = iota
+	// Starts with poisson disk points and refines them where the image has detail.
+	POINT_DISTRIBUTION_ADAPTIVE = iota
+)
+
+const (
+	CELL_SHAPE_SHARP   = iota
+	CELL_SHAPE_ROUNDED = iota
+	CELL_SHAPE_SMOOTH  = iota
+)
+
+// Where adaptive edge colors come from. Keep these in sync with edges.geo!
+const (
+	// Samples the image along the whole edge.
+	EDGE_COLOR_IMAGE = iota
+	// Uses the colors of the two faces next to the edge.
+	EDGE_COLOR_CELLS = iota
+)
+
+const (
+	EDGE_BLEND_AVERAGE = iota
+	// The darker of both sides of the edge.
+	EDGE_BLEND_DARKER = iota
+	// From one end of the edge to the other.
+	EDGE_BLEND_GRADIENT = iota
+)
+
+// Keep these in sync with lines.frag!
+const (
+	// Round ends, so the lines meeting at a vertex join like round joins.
+	LINE_JOIN_ROUND = iota
+	// Sharp corners where exactly two segments meet (outlines, the hull, ...). Where three or more meet,
+	// like at most Voronoi vertices, there is no single miter and the ends get square caps instead.
+	LINE_JOIN_MITER = iota
+)
+
+// What is behind all layers.
+const (
+	// Saved images are transparent where nothing is drawn.
+	BACKGROUND_TRANSPARENT = iota
+	BACKGROUND_COLOR       = iota
+	// Transparent as well, but the window shows a checkerboard to make the transparency visible.
+	BACKGROUND_CHECKERBOARD = iota
+	// The unchanged source image.
+	BACKGROUND_IMAGE = iota
 )
 
 ///////////////////////////////////////////////////////
@@ -52,12 +106,36 @@ var g_windowHeight int = 1000
 //var g_windowHeight float64 = 1000
 
 var g_delaunayPointCount int
+var g_delaunay sc.Delaunay
+var g_voronoi sc.Voronoi
+
+// Cells of the last rebuild, before and after shaping. Recoloring only needs these, the points stay where they are.
+var g_voronoiCells []VoronoiCell
+var g_shapedCells []VoronoiCell
+var g_shapedTiles []VoronoiCell
 var g_delaunayTriangleGLBuffer geo.ArrayGeometry
 var g_delaunayEdgesGLBuffer geo.ArrayGeometry
-var g_delaunayPointsGLBuffer geo.Geometry
-var g_convexHullGLBuffer geo.Geometry
+var g_delaunayPointsGLBuffer geo.ArrayGeometry
+var g_convexHullGLBuffer geo.ArrayGeometry
 var g_voronoiEdgesGLBuffer geo.ArrayGeometry
 var g_voronoiTriangleGLBuffer geo.ArrayGeometry
+var g_paletteTriangleGLBuffer geo.ArrayGeometry
+var g_mosaicTriangleGLBuffer geo.ArrayGeometry
+var g_leadingGLBuffer geo.ArrayGeometry
+var g_fullscreenQuadGLBuffer geo.Geometry
+var g_alphaShapeTriangleGLBuffer geo.ArrayGeometry
+var g_alphaShapeEdgesGLBuffer geo.ArrayGeometry
+var g_mstGLBuffer geo.ArrayGeometry
+var g_gabrielGLBuffer geo.ArrayGeometry
+var g_rngGLBuffer geo.ArrayGeometry
+var g_knnGLBuffer geo.ArrayGeometry
+var g_worleyGrid SiteGrid
+var g_worleyGridTexture uint32
+var g_worleySiteTexture uint32
+var g_metricVoronoiTexture uint32
+
+// Cell colors of the metric Voronoi diagram. Exports paint them into labels at the export resolution.
+var g_metricVoronoiColors []mgl32.Vec4
 
 ///////////////////////////////////////////////////////
 // Camera
@@ -71,7 +149,42 @@ var g_farPlane = float32(2000.0)
 // Delaunay Rendering Options
 ///////////////////////////////////////////////////////
 var g_delaunayDistribution int = POINT_DISTRIBUTION_POISSON
+var g_adaptiveThreshold float64 = 0.08
+var g_adaptiveMaxDepth int = 4
+var g_adaptivePointBudget int = 5000
+
+// Called for every finished refinement level, so the controls can show the progress.
+var g_adaptiveProgress func(level, maxDepth, pointCount int)
+var g_optimizeTriangles = false
+var g_optimizerIterations int = 2000
+var g_optimizerTimeBudget time.Duration = 5 * time.Second
+
+// Called from the optimizer goroutine every few steps, so the controls can show the progress.
+var g_optimizerProgress func(step int, done float64)
+
+// The last finished optimization and the one still running in the background (nil if there is none).
+var g_optimizedInput optimizerInput
+var g_optimizedPoints []sc.Vector
+var g_optimizerJob *optimizerJob
+
+// The last finished exact colors and the calculation still running in the background (nil if there is none).
+var g_exactColorInput exactColorInput
+var g_exactColors exactColors
+var g_exactColorsDone = false
+var g_exactColorJob *exactColorJob
+
+// The buffers contain the exact colors. Otherwise the shader preview is shown.
+var g_exactColorsShown = false
 var g_delaunayTexture mtgl.ImageTexture
+var g_sourceImage *image.RGBA
+
+// Optional second image the colors are sampled from. The geometry still follows g_sourceImage.
+var g_colorSourceImage *image.RGBA
+var g_colorImageAlignment = g_defaultImageAlignment
+
+// What all colors are sampled from: g_colorSourceImage aligned to the size of g_sourceImage, or g_sourceImage itself.
+var g_colorImage *image.RGBA
+var g_colorTexture uint32
 var g_showDelaunayTexture = false
 var g_renderVoronoiCells = false
 var g_renderVoronoiEdges = false
@@ -83,17 +196,106 @@ var g_useExternalColor int32 = 0
 var g_voronoiLineColor mgl32.Vec4
 var g_delaunayLineColor mgl32.Vec4
 var g_pointColor mgl32.Vec4
+
+// Diameter in pixels, scaled per point by g_pointSizeMode.
+var g_pointSize float32 = 5
+var g_pointShape int = POINT_SHAPE_CIRCLE
+var g_pointSizeMode int = POINT_SIZE_FIXED
 var g_chColor mgl32.Vec4
 
+// Line widths in pixels.
+var g_voronoiLineWidth float32 = 1
+var g_delaunayLineWidth float32 = 1
+var g_chLineWidth float32 = 1
+var g_lineJoin int = LINE_JOIN_ROUND
+var g_renderMosaic = false
+var g_mosaicGap float64 = 4.0
+var g_mosaicGapVariation float64 = 0.0
+var g_groutColor mgl32.Vec4
+var g_background int = BACKGROUND_TRANSPARENT
+var g_backgroundColor mgl32.Vec4
+
+// The checkerboard is only a preview and must not end up in saved images.
+var g_exportingImage = false
+
+// Width of exported images in pixels, 0 is the resolution of the source image.
+var g_exportWidth int = 0
+var g_exportDPI int = 300
+var g_checkerboardSize float32 = 8
+var g_groutUseImage = false
+var g_groutImageBrightness float32 = 0.35
+var g_cellShape int = CELL_SHAPE_SHARP
+var g_cellCornerRadius float64 = 8.0
+var g_cellSmoothIterations int = 3
+var g_renderPaletteCells = false
+var g_cellPalette []mgl32.Vec4
+var g_paletteColoringMethod int = GRAPH_COLORING_FOUR
+var g_paletteBalanced = false
+var g_cellColorMode int = CELL_COLOR_PREVIEW
+var g_faceShading int = SHADING_FLAT
+var g_colorSpace int = COLOR_SPACE_SRGB
+var g_colorAdjustment = g_defaultColorAdjustment
+var g_renderStainedGlass = false
+var g_leadingWidth float64 = 6.0
+var g_leadingColor mgl32.Vec4
+var g_glassGlow float32 = 0.25
+var g_glassTexture float32 = 0.08
+var g_glassSaturation float32 = 1.2
+var g_edgeColorSource int = EDGE_COLOR_IMAGE
+var g_edgeBlend int = EDGE_BLEND_AVERAGE
+var g_relief = Relief{Mode: RELIEF_NONE, Height: 10}
+var g_light = DirectionalLight{Azimuth: 135, Elevation: 45, Ambient: 0.3, Specular: 0.3, Shininess: 32}
+var g_quantizeMethod int = QUANTIZE_NONE
+var g_quantizeColorCount int = 8
+var g_quantizedPalette []mgl32.Vec4
+
+// The palette is written next to every saved image while quantization is active.
+var g_paletteFileExtension = ".gpl"
+var g_cellGraph CellGraph
+var g_renderAlphaShape = false
+var g_renderAlphaShapeFill = false
+var g_alphaShapeFactor float64 = 2.0
+var g_alphaShapeColor mgl32.Vec4
+var g_alphaShapeLineWidth float32 = 1
+var g_renderMST = false
+var g_renderGabriel = false
+var g_renderRNG = false
+var g_renderKNN = false
+var g_knnCount int = 3
+var g_mstColor mgl32.Vec4
+var g_gabrielColor mgl32.Vec4
+var g_rngColor mgl32.Vec4
+var g_knnColor mgl32.Vec4
+var g_mstLineWidth float32 = 1
+var g_gabrielLineWidth float32 = 1
+var g_rngLineWidth float32 = 1
+var g_knnLineWidth float32 = 1
+var g_renderWorley = false
+var g_worleyMode int = WORLEY_F1
+var g_worleyInvert = false
+var g_worleyModulate = false
+var g_renderMetricVoronoi = false
+var g_voronoiMetric int = METRIC_MANHATTAN
+var g_minkowskiExponent float64 = 3.0
+
+// The metric Voronoi raster is expensive, so it is only calculated when it is actually shown.
+var g_metricVoronoiOutdated = true
+
 ///////////////////////////////////////////////////////
 // OpenGL Setup
 ///////////////////////////////////////////////////////
 var g_delaunayTrianglesShader uint32
 var g_delaunayEdgesShader uint32
 var g_delaunayPointsShader uint32
-var g_sceneColorTexMS uint32
-var g_sceneDepthTexMS uint32
-var g_sceneFboMS uint32
+var g_imageShader uint32
+var g_worleyShader uint32
+var g_stainedGlassShader uint32
+var g_sceneMultisample MultisampleTarget
+var g_lineLayerShader uint32
+var g_lineLayer LineLayerTarget
+
+// Samples per pixel for the anti-aliasing. Less than two renders directly into the window.
+var g_multisampleCount int32 = 4
 
 ///////////////////////////////////////////////////////
 // Control Communication
@@ -101,6 +303,9 @@ var g_sceneFboMS uint32
 var g_controlCommunication chan func()
 var g_controlCommunicationClose chan int
 var g_readyForRebuild bool = false
+
+// Only the colors are calculated again, see recolorDelaunayTriangulation. A rebuild does that anyway.
+var g_readyForRecolor bool = false
 var g_readyForRender bool = false
 
 ///////////////////////////////////////////////////////
@@ -248,158 +453,386 @@ func createDelaunay(count int, rangeX, rangeY, margin float64) sc.Delaunay {
 	case POINT_DISTRIBUTION_GRID:
 		list = CreateShiftedGridPoints(count, rangeX, rangeY, margin)
 
+	case POINT_DISTRIBUTION_ADAPTIVE:
+		adjustedCount := count
+		if count < 3 {
+			adjustedCount = 3
+		}
+
+		list = CreateFastPoissonDiscPoints(adjustedCount, rangeX, rangeY, margin, 30, seed)
+
+		maxDepth := g_adaptiveMaxDepth
+		progress := g_adaptiveProgress
+		if progress != nil {
+			progress(0, maxDepth, len(list))
+		}
+		list = CreateAdaptivePoints(list, g_sourceImage, g_adaptiveThreshold, maxDepth, g_adaptivePointBudget, rangeX, rangeY, margin, func(level, pointCount int) {
+			if progress != nil {
+				progress(level, maxDepth, pointCount)
+			}
+		})
+		if progress != nil {
+			// Done, even if we stopped before the maximum depth.
+			progress(maxDepth, maxDepth, len(list))
+		}
+
 	default:
 		fmt.Println("No point distribution selected. Default to random.")
 		list = CreateRandomPoints(count, rangeX, rangeY, margin, seed)
 	}
 
+	if g_optimizeTriangles {
+		list = optimizedPoints(optimizerInput{list, g_sourceImage, g_optimizerIterations, g_optimizerTimeBudget, rangeX, rangeY, seed})
+	} else {
+		cancelOptimizer()
+	}
+
 	fmt.Printf("Points: %d\n", len(list))
 
 	return sc.Triangulate(list)
 }
 
-func createDelaunayGLBuffer(d sc.Delaunay, rangeX, rangeY float64) geo.ArrayGeometry {
-	mesh := make([]geo.Mesh, len(d.Faces)*3)
+// Returns the optimized points for input, if they are known. Otherwise the optimizer is started in the background
+// and input.points are returned for now. When it is done, everything is rebuilt with the optimized points.
+// Rebuilds that do not change the points (colors, cell shapes, ...) get the cached result and do not optimize again.
+func optimizedPoints(input optimizerInput) []sc.Vector {
+	// Triangulate might reorder the list it gets. The cache and the goroutine need their own copies.
+	if g_optimizedPoints != nil && g_optimizedInput.equals(input) {
+		return append([]sc.Vector{}, g_optimizedPoints...)
+	}
+	if g_optimizerJob != nil && g_optimizerJob.input.equals(input) {
+		return input.points
+	}
+	cancelOptimizer()
+
+	input.points = append([]sc.Vector{}, input.points...)
+	job := &optimizerJob{input, make(chan struct{})}
+	g_optimizerJob = job
+	progress := g_optimizerProgress
+
+	go func() {
+		points, ok := OptimizeTriangulation(input.points, input.img, input.iterations, input.budget, input.rangeX, input.rangeY, input.seed, job.cancel, progress)
+		if !ok {
+			return
+		}
+		g_controlCommunication <- func() {
+			// The settings might have changed while the message was waiting.
+			if g_optimizerJob != job {
+				return
+			}
+			g_optimizerJob = nil
+			g_optimizedInput = input
+			g_optimizedPoints = points
+			ReadyForRebuild(true)
+			ReadyForRender(true)
+		}
+	}()
+
+	return append([]sc.Vector{}, input.points...)
+}
+
+func cancelOptimizer() {
+	if g_optimizerJob != nil {
+		close(g_optimizerJob.cancel)
+		g_optimizerJob = nil
+	}
+}
+
+// Returns the exact colors for input, if they are known. Otherwise they are calculated in the background
+// and false is returned for now. When they are done, everything is recolored with them.
+// Rebuilds that do not change the points or the color settings get the cached colors.
+func exactCellColors(input exactColorInput, cells []VoronoiCell, triangles [][3]sc.Vector) (exactColors, bool) {
+	if g_exactColorsDone && g_exactColorInput.equals(input) {
+		return g_exactColors, true
+	}
+	if g_exactColorJob != nil && g_exactColorJob.input.equals(input) {
+		return exactColors{}, false
+	}
+	cancelExactColors()
+
+	// The settings are changed on this thread, the goroutine needs its own copies.
+	input.points = append([]sc.Vector{}, input.points...)
+	input.palette = append([]mgl32.Vec4{}, input.palette...)
+	job := &exactColorJob{input, make(chan struct{})}
+	g_exactColorJob = job
+
+	go func() {
+		colors, ok := computeExactColors(input, cells, triangles, job.cancel)
+		if !ok {
+			return
+		}
+		g_controlCommunication <- func() {
+			// The settings might have changed while the message was waiting.
+			if g_exactColorJob != job {
+				return
+			}
+			g_exactColorJob = nil
+			g_exactColorInput = input
+			g_exactColors = colors
+			g_exactColorsDone = true
+			ReadyForRecolor(true)
+			ReadyForRender(true)
+		}
+	}()
+
+	return exactColors{}, false
+}
+
+func cancelExactColors() {
+	if g_exactColorJob != nil {
+		close(g_exactColorJob.cancel)
+		g_exactColorJob = nil
+	}
+}
+
+// Texture coordinate of a window position. Also identifies cells for the color jitter.
+func positionUV(p sc.Vector, rangeX, rangeY float64) mgl32.Vec2 {
+	return mgl32.Vec2{float32(p.X / rangeX), float32(p.Y / rangeY)}
+}
+
+func triangleCenterUV(t [3]sc.Vector, rangeX, rangeY float64) mgl32.Vec2 {
+	return positionUV(t[0], rangeX, rangeY).Add(positionUV(t[1], rangeX, rangeY).Add(positionUV(t[2], rangeX, rangeY))).Mul(1.0 / 3.0)
+}
+
+// colors holds one color (gradient) per face and is only used, if the color is not sampled in the shader.
+// With vertexUV, the shader samples the image at every vertex instead of at the triangle center (Gouraud shading).
+// relief gives the faces their normals, pyramids split every triangle at its center.
+func createDelaunayGLBuffer(d sc.Delaunay, colors []ColorGradient, vertexUV bool, relief Relief, img *image.RGBA, rangeX, rangeY float64) geo.ArrayGeometry {
+	mesh := make([]ColorMesh, 0, len(d.Faces)*3)
 
 	for i, f := range d.Faces {
 		v1 := d.Vertices[d.Edges[f.EEdge].VOrigin].Pos
 		v2 := d.Vertices[d.Edges[d.Edges[f.EEdge].ENext].VOrigin].Pos
 		v3 := d.Vertices[d.Edges[d.Edges[d.Edges[f.EEdge].ENext].ENext].VOrigin].Pos
 
-		uv1 := mgl32.Vec2{float32(v1.X / rangeX), float32(v1.Y / rangeY)}
-		uv2 := mgl32.Vec2{float32(v2.X / rangeX), float32(v2.Y / rangeY)}
-		uv3 := mgl32.Vec2{float32(v3.X / rangeX), float32(v3.Y / rangeY)}
+		averageUV := triangleCenterUV([3]sc.Vector{v1, v2, v3}, rangeX, rangeY)
 
-		averageUV := uv1.Add(uv2.Add(uv3)).Mul(1.0 / 3.0)
+		gradient := ColorGradient{Color: mgl32.Vec4{1, 1, 1, 1}}
+		if i < len(colors) {
+			gradient = colors[i]
+		}
 
-		mesh[i*3] = geo.Mesh{mgl32.Vec3{float32(v1.X), float32(v1.Y), 0}, mgl32.Vec3{0.0, 0.0, 1.0}, averageUV}
-		mesh[i*3+1] = geo.Mesh{mgl32.Vec3{float32(v2.X), float32(v2.Y), 0}, mgl32.Vec3{0.0, 0.0, 1.0}, averageUV}
-		mesh[i*3+2] = geo.Mesh{mgl32.Vec3{float32(v3.X), float32(v3.Y), 0}, mgl32.Vec3{0.0, 0.0, 1.0}, averageUV}
+		center := sc.Mult(sc.Add(v1, sc.Add(v2, v3)), 1.0/3.0)
+		facets, normals := reliefFacets([]sc.Vector{v1, v2, v3}, center, relief, img, rangeX, rangeY)
+		for j, t := range facets {
+			for _, v := range t {
+				uv := averageUV
+				if vertexUV {
+					uv = positionUV(v, rangeX, rangeY)
+				}
+				mesh = append(mesh, ColorMesh{mgl32.Vec3{float32(v.X), float32(v.Y), 0}, normals[j], uv, gradient.At(v)})
+			}
+		}
 	}
 
-	return geo.GenerateGeometryArrayAttributes(&mesh, len(mesh))
+	return generateColorGeometryArrayAttributes(&mesh, len(mesh))
 }
 
-func createVoronoiGLBuffer(vo sc.Voronoi, rangeX, rangeY float64) geo.ArrayGeometry {
-	mesh := make([]geo.Mesh, 0)
+// colors optionally assigns a color to every cell (same index). Otherwise the cells are just white.
+// relief gives the cells their normals, pyramids peak at the site.
+func createVoronoiGLBuffer(cells []VoronoiCell, colors []ColorGradient, relief Relief, img *image.RGBA, rangeX, rangeY float64) geo.ArrayGeometry {
+	mesh := make([]ColorMesh, 0)
 
-	emptyF := sc.HEFace{}
+	for i, c := range cells {
 
-	for _, f := range vo.Faces {
+		if len(c.Polygon) < 3 {
+			continue
+		}
 
-		if f == emptyF {
-			break
+		averageUV := positionUV(c.Site, rangeX, rangeY)
+
+		gradient := ColorGradient{Color: mgl32.Vec4{1, 1, 1, 1}}
+		if i < len(colors) {
+			gradient = colors[i]
 		}
 
-		e0 := f.EEdge
-		e1 := vo.Edges[e0].ENext
+		facets, normals := reliefFacets(c.Polygon, c.Site, relief, img, rangeX, rangeY)
+		for j, t := range facets {
+			for _, v := range t {
+				// The assigned averageUV is not correct and must be overwritten later! (Just placeholder now for the real one later)
+				mesh = append(mesh, ColorMesh{mgl32.Vec3{float32(v.X), float32(v.Y), 0}, normals[j], averageUV, gradient.At(v)})
+			}
+		}
+	}
 
-		v0 := sc.Vector{}
-		v1 := sc.Vector{}
+	return generateColorGeometryArrayAttributes(&mesh, len(mesh))
+}
 
-		// For the Voronoi case
-		shouldBreak := false
+// Every edge carries the colors of its two faces: the first vertex the color of the face on its left,
+// the second vertex the one on its right. edges.geo decides how to use them.
+// faceColors is indexed like d.Faces. Edges at the border use the color of their only face.
+// Like all lines, the edges are lines with adjacency, see segmentNeighbors.
+func createDelaunayEdgesGLBuffer(d sc.Delaunay, faceColors []mgl32.Vec4, rangeX, rangeY float64) geo.ArrayGeometry {
+	segments := make([][2]sc.Vector, 0)
+	colors := make([][2]mgl32.Vec4, 0)
+
+	normal := mgl32.Vec3{0.0, 0.0, 1.0}
+
+	faceColor := func(f sc.FaceIndex) (mgl32.Vec4, bool) {
+		if f < 0 || int(f) >= len(faceColors) {
+			return mgl32.Vec4{1, 1, 1, 1}, false
+		}
+		return faceColors[f], true
+	}
+
+	for i, e := range d.Edges {
+		twin := d.Edges[e.ETwin]
+		// Every edge only once.
+		if int(e.ETwin) <= i {
+			continue
+		}
+		// Open Voronoi edges only have one vertex and are extended like in ExtractEdgeList.
+		var v1, v2 sc.Vector
 		switch {
-		// Both are empty
-		case vo.Edges[e0].VOrigin == sc.EmptyVertex && vo.Edges[vo.Edges[e0].ETwin].VOrigin == sc.EmptyVertex:
-			shouldBreak = true
-		// Only the left one is empty
-		case vo.Edges[e0].VOrigin == sc.EmptyVertex:
-			v1 = vo.Vertices[vo.Edges[vo.Edges[e0].ETwin].VOrigin].Pos
-			v0 = sc.Add(vo.Edges[e0].TmpEdge.Pos, sc.Mult(vo.Edges[e0].TmpEdge.Dir, -10.0))
-		// Only the right one is empty
-		case vo.Edges[vo.Edges[e0].ETwin].VOrigin == sc.EmptyVertex:
-			v0 = vo.Vertices[vo.Edges[e0].VOrigin].Pos
-			v1 = sc.Add(vo.Edges[e0].TmpEdge.Pos, sc.Mult(vo.Edges[e0].TmpEdge.Dir, -10.0))
-		// No one is empty!
+		case e.VOrigin.Valid() && twin.VOrigin.Valid():
+			v1, v2 = d.Vertices[e.VOrigin].Pos, d.Vertices[twin.VOrigin].Pos
+		case twin.VOrigin.Valid():
+			v2 = d.Vertices[twin.VOrigin].Pos
+			v1 = sc.Add(v2, sc.Mult(e.TmpEdge.Dir, -10))
+		case e.VOrigin.Valid():
+			v1 = d.Vertices[e.VOrigin].Pos
+			v2 = sc.Add(v1, sc.Mult(e.TmpEdge.Dir, -10))
 		default:
-			v0 = vo.Vertices[vo.Edges[e0].VOrigin].Pos
-			v1 = vo.Vertices[vo.Edges[vo.Edges[e0].ETwin].VOrigin].Pos
+			continue
 		}
 
-		if shouldBreak {
-			break
+		c1, ok1 := faceColor(e.FFace)
+		c2, ok2 := faceColor(twin.FFace)
+		if !ok1 {
+			c1 = c2
+		}
+		if !ok2 {
+			c2 = c1
 		}
 
-		p := vo.Faces[vo.Edges[e0].FFace].ReferencePoint
-		averageUV := mgl32.Vec2{float32(p.X / rangeX), float32(p.Y / rangeY)}
+		segments = append(segments, [2]sc.Vector{v1, v2})
+		colors = append(colors, [2]mgl32.Vec4{c1, c2})
+	}
 
-		for e1 != sc.EmptyEdge && e1 != e0 {
+	mesh := make([]ColorMesh, 0, 4*len(segments))
+	for i, n := range segmentNeighbors(segments) {
+		for j, v := range []sc.Vector{n[0], segments[i][0], segments[i][1], n[1]} {
+			mesh = append(mesh, ColorMesh{mgl32.Vec3{float32(v.X), float32(v.Y), 0}, normal, positionUV(v, rangeX, rangeY), colors[i][j/2]})
+		}
+	}
+
+	return generateColorGeometryArrayAttributes(&mesh, len(mesh))
+}
 
-			v1 = vo.Vertices[vo.Edges[e1].VOrigin].Pos
-			v2 := sc.Vector{}
-			if vo.Edges[vo.Edges[e1].ETwin].VOrigin == sc.EmptyVertex {
-				v2 = sc.Add(vo.Edges[e1].TmpEdge.Pos, sc.Mult(vo.Edges[e1].TmpEdge.Dir, -10.0))
-			} else {
-				v2 = vo.Vertices[vo.Edges[vo.Edges[e1].ETwin].VOrigin].Pos
+// The neighbors of both ends of every segment for the miter joins in edges.geo: the far end of the only other
+// segment that ends at the same point. Where no or more than one other segment ends, the end is its own neighbor.
+// Every segment becomes four vertices (lines with adjacency): neighbor, start, end, neighbor.
+func segmentNeighbors(segments [][2]sc.Vector) [][2]sc.Vector {
+	ends := make(map[sc.Vector][]int)
+	for i, s := range segments {
+		ends[s[0]] = append(ends[s[0]], 2*i)
+		ends[s[1]] = append(ends[s[1]], 2*i+1)
+	}
+
+	neighbors := make([][2]sc.Vector, len(segments))
+	for i, s := range segments {
+		for end := 0; end < 2; end++ {
+			neighbors[i][end] = s[end]
+			at := ends[s[end]]
+			if len(at) != 2 {
+				continue
+			}
+			other := at[0]
+			if other == 2*i+end {
+				other = at[1]
 			}
+			neighbors[i][end] = segments[other/2][1-other%2]
+		}
+	}
+	return neighbors
+}
+
+// One color per face for edges that are colored by their neighbors: the CPU color at the face center
+// if there is one, the same color the shader preview samples otherwise. uvs identify the faces like in the shader.
+func edgeFaceColors(centers []sc.Vector, uvs []mgl32.Vec2, colors []ColorGradient, expectedRadius, rangeX, rangeY float64) []mgl32.Vec4 {
+	result := make([]mgl32.Vec4, len(centers))
+	r := mgl32.Vec2{float32(expectedRadius / rangeX), float32(expectedRadius / rangeY)}
+	for i, c := range centers {
+		if i < len(colors) {
+			result[i] = colors[i].At(c)
+		} else {
+			result[i] = sampleImageRandom(g_colorImage, r, mgl32.Vec2{uvs[i].X(), 1 - uvs[i].Y()}, g_colorSpace)
+		}
+		// Quantized colors are already adjusted.
+		if g_colorAdjustment.Active() && (g_quantizeMethod == QUANTIZE_NONE || i >= len(colors)) {
+			result[i] = g_colorAdjustment.Apply(result[i].Vec3(), uvs[i]).Vec4(result[i].W())
+		}
+	}
+	return result
+}
+
+// Lines for arbitrary edges (that are not directly part of the Delaunay or Voronoi).
+func createSimpleEdgesGLBuffer(edges []sc.SimpleEdge, rangeX, rangeY float64) geo.ArrayGeometry {
+	segments := make([][2]sc.Vector, len(edges))
+	for i, e := range edges {
+		segments[i] = [2]sc.Vector{e.V1, e.V2}
+	}
 
-			// The assigned averageUV is not correct and must be overwritten later! (Just placeholder now for the real one later)
-			mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v0.X), float32(v0.Y), 0}, mgl32.Vec3{0.0, 0.0, 1.0}, averageUV})
-			mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v1.X), float32(v1.Y), 0}, mgl32.Vec3{0.0, 0.0, 1.0}, averageUV})
-			mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v2.X), float32(v2.Y), 0}, mgl32.Vec3{0.0, 0.0, 1.0}, averageUV})
+	mesh := make([]geo.Mesh, 0, 4*len(edges))
 
-			e1 = vo.Edges[e1].ENext
+	normal := mgl32.Vec3{0.0, 0.0, 1.0}
+
+	for i, n := range segmentNeighbors(segments) {
+		for _, v := range []sc.Vector{n[0], segments[i][0], segments[i][1], n[1]} {
+			uv := mgl32.Vec2{float32(v.X / rangeX), float32(v.Y / rangeY)}
+			mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v.X), float32(v.Y), 0}, normal, uv})
 		}
 	}
 
 	return geo.GenerateGeometryArrayAttributes(&mesh, len(mesh))
 }
 
-func createDelaunayEdgesGLBuffer(d sc.Delaunay, rangeX, rangeY float64) geo.ArrayGeometry {
-	mesh := make([]geo.Mesh, 0)
+// Flat triangles, sampled at their center just like the Delaunay triangles.
+func createTrianglesGLBuffer(triangles [][3]sc.Vector, rangeX, rangeY float64) geo.ArrayGeometry {
+	mesh := make([]geo.Mesh, 0, 3*len(triangles))
 
 	normal := mgl32.Vec3{0.0, 0.0, 1.0}
 
-	dEdges := d.ExtractEdgeList()
-	for _, e := range dEdges {
-		uv1 := mgl32.Vec2{float32(e.V1.X / rangeX), float32(e.V1.Y / rangeY)}
-		uv2 := mgl32.Vec2{float32(e.V1.X / rangeX), float32(e.V1.Y / rangeY)}
-		mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(e.V1.X), float32(e.V1.Y), 0}, normal, uv1})
-		mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(e.V2.X), float32(e.V2.Y), 0}, normal, uv2})
+	for _, t := range triangles {
+		center := sc.Mult(sc.Add(t[0], sc.Add(t[1], t[2])), 1.0/3.0)
+		averageUV := mgl32.Vec2{float32(center.X / rangeX), float32(center.Y / rangeY)}
+		for _, v := range t {
+			mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v.X), float32(v.Y), 0}, normal, averageUV})
+		}
 	}
 
 	return geo.GenerateGeometryArrayAttributes(&mesh, len(mesh))
 }
 
-func createDelaunayPointsGLBuffer(d sc.Delaunay, rangeX, rangeY float64) geo.Geometry {
-	mesh := make([]geo.Mesh, 0)
-	indices := make([]uint32, 0)
+// sizes is indexed like the Delaunay vertices, see pointSizeFactors.
+func createDelaunayPointsGLBuffer(d sc.Delaunay, sizes []float32, rangeX, rangeY float64) geo.ArrayGeometry {
+	mesh := make([]PointMesh, 0, len(d.Vertices))
 
 	normal := mgl32.Vec3{0.0, 0.0, 1.0}
 
 	for i, v := range d.Vertices {
 		uv1 := mgl32.Vec2{float32(v.Pos.X / rangeX), float32(v.Pos.Y / rangeY)}
-		mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v.Pos.X), float32(v.Pos.Y), 0}, normal, uv1})
-
-		indices = append(indices, uint32(i))
+		mesh = append(mesh, PointMesh{mgl32.Vec3{float32(v.Pos.X), float32(v.Pos.Y), 0}, normal, uv1, sizes[i]})
 	}
 
-	return geo.GenerateGeometryAttributes(&mesh, &indices, len(mesh), len(indices))
+	return generatePointGeometryArrayAttributes(&mesh, len(mesh))
 }
 
-func createConvexHullGLBuffer(d sc.Delaunay, rangeX, rangeY float64) geo.Geometry {
-	mesh := make([]geo.Mesh, 0)
-	indices := make([]uint32, 0)
-
-	normal := mgl32.Vec3{0.0, 0.0, 1.0}
-
+// The closed loop of the convex hull as single segments, so the corners get the same joins as all other lines.
+func createConvexHullGLBuffer(d sc.Delaunay, rangeX, rangeY float64) geo.ArrayGeometry {
 	ch := d.ExtractConvexHull()
 
+	edges := make([]sc.SimpleEdge, len(ch))
 	for i, v := range ch {
-		uv1 := mgl32.Vec2{float32(v.X / rangeX), float32(v.Y / rangeY)}
-		mesh = append(mesh, geo.Mesh{mgl32.Vec3{float32(v.X), float32(v.Y), 0}, normal, uv1})
-
-		indices = append(indices, uint32(i))
+		edges[i] = sc.SimpleEdge{v, ch[(i+1)%len(ch)]}
 	}
-	// One extra index to the first element to close the loop!
-	indices = append(indices, 0)
 
-	return geo.GenerateGeometryAttributes(&mesh, &indices, len(mesh), len(indices))
+	return createSimpleEdgesGLBuffer(edges, rangeX, rangeY)
 }
 
-func createVoronoiEdgesGLBuffer(v sc.Voronoi, rangeX, rangeY float64) geo.ArrayGeometry {
-	return createDelaunayEdgesGLBuffer(sc.Delaunay(v), rangeX, rangeY)
+// cellColors is indexed like the Voronoi faces (and cells).
+func createVoronoiEdgesGLBuffer(v sc.Voronoi, cellColors []mgl32.Vec4, rangeX, rangeY float64) geo.ArrayGeometry {
+	return createDelaunayEdgesGLBuffer(sc.Delaunay(v), cellColors, rangeX, rangeY)
 }
 
 func createInterpolationControlBuffer(geometry geo.ArrayGeometry) uint {
@@ -411,7 +844,81 @@ func createInterpolationControlBuffer(geometry geo.ArrayGeometry) uint {
 	return positionBuffer
 }
 
-func freeGLBuffers() {
+// Single channel float data would be enough for most things. But RG32F keeps it simple and lets us store pairs.
+func createFloatTexture(width, height int, data []float32) uint32 {
+	var tex uint32
+	gl.GenTextures(1, &tex)
+	gl.BindTexture(gl.TEXTURE_2D, tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RG32F, int32(width), int32(height), 0, gl.RG, gl.FLOAT, gl.Ptr(data))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return tex
+}
+
+func createRGBATexture(img *image.RGBA) uint32 {
+	var tex uint32
+	gl.GenTextures(1, &tex)
+	gl.BindTexture(gl.TEXTURE_2D, tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(img.Rect.Dx()), int32(img.Rect.Dy()), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return tex
+}
+
+// Uploads the site grid for worley.frag. Returns the grid texture and the site texture.
+func createWorleyTextures(grid SiteGrid) (uint32, uint32) {
+	gridData := make([]float32, 0, 2*grid.Width*grid.Height)
+	for i := 0; i < grid.Width*grid.Height; i++ {
+		gridData = append(gridData, float32(grid.Start[i]), float32(grid.Start[i+1]))
+	}
+
+	siteRows := len(grid.Sites)/g_siteTextureWidth + 1
+	siteData := make([]float32, 2*g_siteTextureWidth*siteRows)
+	for i, s := range grid.Sites {
+		siteData[2*i] = float32(s.X)
+		siteData[2*i+1] = float32(s.Y)
+	}
+
+	return createFloatTexture(grid.Width, grid.Height, gridData), createFloatTexture(g_siteTextureWidth, siteRows, siteData)
+}
+
+// Colors all cells with the palette, so no two neighbors get the same color (if the palette is big enough).
+func createPaletteCellColors(graph CellGraph, palette []mgl32.Vec4, method int, balanced bool) []mgl32.Vec4 {
+	if len(palette) == 0 {
+		return nil
+	}
+
+	var coloring []int
+	switch method {
+	case GRAPH_COLORING_FOUR:
+		coloring = ColorGraphFourColor(graph, len(palette), balanced)
+	default:
+		coloring = ColorGraphGreedy(graph, len(palette), balanced)
+	}
+
+	if !graph.IsProperColoring(coloring) {
+		if method == GRAPH_COLORING_FOUR && len(palette) >= 4 {
+			fmt.Println("The four color search gave up before all equal neighbors were resolved. Try the greedy coloring or more colors.")
+		} else {
+			fmt.Printf("A palette of %v colors is too small for a coloring without equal neighbors.\n", len(palette))
+		}
+	}
+
+	colors := make([]mgl32.Vec4, len(coloring))
+	for i, c := range coloring {
+		colors[i] = palette[c]
+	}
+	return colors
+}
+
+// Buffers that contain image colors. They are replaced when only the colors change.
+func freeColorGLBuffers() {
 	gl.DeleteBuffers(1, &g_delaunayTriangleGLBuffer.ArrayBuffer)
 	gl.DeleteVertexArrays(1, &g_delaunayTriangleGLBuffer.VertexBuffer)
 
@@ -419,15 +926,46 @@ func freeGLBuffers() {
 	gl.DeleteVertexArrays(1, &g_delaunayEdgesGLBuffer.VertexBuffer)
 
 	gl.DeleteBuffers(1, &g_delaunayPointsGLBuffer.ArrayBuffer)
-	gl.DeleteBuffers(1, &g_delaunayPointsGLBuffer.IndexBuffer)
 	gl.DeleteVertexArrays(1, &g_delaunayPointsGLBuffer.VertexBuffer)
 
-	gl.DeleteBuffers(1, &g_convexHullGLBuffer.ArrayBuffer)
-	gl.DeleteBuffers(1, &g_convexHullGLBuffer.IndexBuffer)
-	gl.DeleteVertexArrays(1, &g_convexHullGLBuffer.VertexBuffer)
+	gl.DeleteBuffers(1, &g_voronoiTriangleGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_voronoiTriangleGLBuffer.VertexBuffer)
 
 	gl.DeleteBuffers(1, &g_voronoiEdgesGLBuffer.ArrayBuffer)
 	gl.DeleteVertexArrays(1, &g_voronoiEdgesGLBuffer.VertexBuffer)
+
+	gl.DeleteBuffers(1, &g_mosaicTriangleGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_mosaicTriangleGLBuffer.VertexBuffer)
+
+	gl.DeleteTextures(1, &g_metricVoronoiTexture)
+	g_metricVoronoiTexture = 0
+}
+
+func freeGLBuffers() {
+	freeColorGLBuffers()
+
+	gl.DeleteBuffers(1, &g_convexHullGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_convexHullGLBuffer.VertexBuffer)
+
+	gl.DeleteBuffers(1, &g_paletteTriangleGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_paletteTriangleGLBuffer.VertexBuffer)
+
+	gl.DeleteBuffers(1, &g_leadingGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_leadingGLBuffer.VertexBuffer)
+
+	gl.DeleteBuffers(1, &g_alphaShapeTriangleGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_alphaShapeTriangleGLBuffer.VertexBuffer)
+
+	gl.DeleteBuffers(1, &g_alphaShapeEdgesGLBuffer.ArrayBuffer)
+	gl.DeleteVertexArrays(1, &g_alphaShapeEdgesGLBuffer.VertexBuffer)
+
+	for _, b := range []*geo.ArrayGeometry{&g_mstGLBuffer, &g_gabrielGLBuffer, &g_rngGLBuffer, &g_knnGLBuffer} {
+		gl.DeleteBuffers(1, &b.ArrayBuffer)
+		gl.DeleteVertexArrays(1, &b.VertexBuffer)
+	}
+
+	gl.DeleteTextures(1, &g_worleyGridTexture)
+	gl.DeleteTextures(1, &g_worleySiteTexture)
 }
 
 /*func redefineProjectionMatrices() {
@@ -446,6 +984,19 @@ func freeGLBuffers() {
     gl.UseProgram(0)
 }*/
 
+// Cell and triangle colors are calculated on the CPU instead of being sampled in the shader.
+func useExactCellColors() bool {
+	return g_cellColorMode != CELL_COLOR_PREVIEW || g_quantizeMethod != QUANTIZE_NONE || g_faceShading == SHADING_GRADIENT
+}
+
+// A quantized image must only contain palette colors, so it is always flat shaded.
+func faceShading() int {
+	if g_quantizeMethod != QUANTIZE_NONE {
+		return SHADING_FLAT
+	}
+	return g_faceShading
+}
+
 func recalculateDelaunayTriangulation() {
 
 	//g_windowWidth = float64(g_windowWidth)
@@ -457,22 +1008,50 @@ func recalculateDelaunayTriangulation() {
 
 	v := d.CreateVoronoi()
 
+	g_delaunay = d
+	g_voronoi = v
+
 	//drawImage(sc.Delaunay(v), "voronoi")
 
 	//fmt.Println(sc.Delaunay(v))
 
 	freeGLBuffers()
 
-	g_voronoiEdgesGLBuffer = createVoronoiEdgesGLBuffer(v, float64(g_windowWidth), float64(g_windowHeight))
-
 	//g_interpolationControlBuffer = createInterpolationControlBuffer(g_voronoiEdgesGLBuffer)
 
-	g_voronoiTriangleGLBuffer = createVoronoiGLBuffer(v, float64(g_windowWidth), float64(g_windowHeight))
-	g_delaunayTriangleGLBuffer = createDelaunayGLBuffer(d, float64(g_windowWidth), float64(g_windowHeight))
-	g_delaunayEdgesGLBuffer = createDelaunayEdgesGLBuffer(d, float64(g_windowWidth), float64(g_windowHeight))
-	g_delaunayPointsGLBuffer = createDelaunayPointsGLBuffer(d, float64(g_windowWidth), float64(g_windowHeight))
+	cells := extractVoronoiCells(v, float64(g_windowWidth), float64(g_windowHeight))
+	tiles := createMosaicTiles(cells, g_mosaicGap, g_mosaicGapVariation, float64(g_windowWidth), float64(g_windowHeight), int64(g_delaunayPointCount))
+
+	// Colors and point sizes depend on the real cell.
+	g_voronoiCells = cells
+	g_shapedCells = shapeCells(cells, g_cellShape, g_cellCornerRadius, g_cellSmoothIterations)
+	g_shapedTiles = shapeCells(tiles, g_cellShape, g_cellCornerRadius, g_cellSmoothIterations)
+
+	g_cellGraph = CreateCellAdjacencyGraph(d)
+	paletteColors := createPaletteCellColors(g_cellGraph, g_cellPalette, g_paletteColoringMethod, g_paletteBalanced)
+
+	g_paletteTriangleGLBuffer = createVoronoiGLBuffer(g_shapedCells, flatGradients(paletteColors), cellRelief(), g_sourceImage, float64(g_windowWidth), float64(g_windowHeight))
+	g_leadingGLBuffer = createTrianglesGLBuffer(createLeadingTriangles(cellPolygons(g_shapedCells), g_leadingWidth, g_leadingJoinSegments), float64(g_windowWidth), float64(g_windowHeight))
 	g_convexHullGLBuffer = createConvexHullGLBuffer(d, float64(g_windowWidth), float64(g_windowHeight))
 
+	expectedRadius := calcExpectedRadius(g_delaunayPointCount, float64(g_windowWidth), float64(g_windowHeight), g_delaunayMargin)
+
+	alphaRadius := g_alphaShapeFactor * expectedRadius
+	alphaShape := CreateAlphaShape(d, alphaRadius)
+	g_alphaShapeTriangleGLBuffer = createTrianglesGLBuffer(alphaShape.Triangles, float64(g_windowWidth), float64(g_windowHeight))
+	g_alphaShapeEdgesGLBuffer = createSimpleEdgesGLBuffer(alphaShape.Boundary, float64(g_windowWidth), float64(g_windowHeight))
+
+	points := delaunayPoints(d)
+	g_mstGLBuffer = createSimpleEdgesGLBuffer(CreateMinimumSpanningTree(points, g_cellGraph), float64(g_windowWidth), float64(g_windowHeight))
+	g_gabrielGLBuffer = createSimpleEdgesGLBuffer(CreateGabrielGraph(points, g_cellGraph), float64(g_windowWidth), float64(g_windowHeight))
+	g_rngGLBuffer = createSimpleEdgesGLBuffer(CreateRelativeNeighborhoodGraph(points, g_cellGraph), float64(g_windowWidth), float64(g_windowHeight))
+	g_knnGLBuffer = createSimpleEdgesGLBuffer(CreateKNearestNeighborGraph(points, g_cellGraph, g_knnCount), float64(g_windowWidth), float64(g_windowHeight))
+
+	g_worleyGrid = CreateSiteGrid(points, float64(g_windowWidth), float64(g_windowHeight), expectedRadius)
+	g_worleyGridTexture, g_worleySiteTexture = createWorleyTextures(g_worleyGrid)
+
+	createColoredGLBuffers()
+
 	//redefineProjectionMatrices()
 
 	// Commented because of OpenGL 3.3 missmatch - Core in OpenGL 4.2
@@ -480,6 +1059,94 @@ func recalculateDelaunayTriangulation() {
 
 }
 
+func cellRelief() Relief {
+	relief := g_relief
+	relief.Radius = calcExpectedRadius(g_delaunayPointCount, float64(g_windowWidth), float64(g_windowHeight), g_delaunayMargin)
+	return relief
+}
+
+// Only the colors changed (for example the alignment of the color image). The triangulation and the cells are kept.
+func recolorDelaunayTriangulation() {
+	freeColorGLBuffers()
+	createColoredGLBuffers()
+}
+
+// Calculates the colors of the current cells and triangles and creates all buffers that contain them.
+func createColoredGLBuffers() {
+	d := g_delaunay
+	v := g_voronoi
+	cells := g_voronoiCells
+
+	// The exact colors are calculated in the background. Until they are done, the faces show the shader preview.
+	var cellColors, triangleColors []ColorGradient
+	triangles := delaunayTriangles(d)
+	g_quantizedPalette = nil
+	g_exactColorsShown = false
+
+	if useExactCellColors() {
+		vertices := make([]sc.Vector, len(d.Vertices))
+		for i, vertex := range d.Vertices {
+			vertices[i] = vertex.Pos
+		}
+		input := exactColorInput{points: vertices, img: g_colorImage, method: g_cellColorMode, shading: faceShading(), space: g_colorSpace,
+			quantize: g_quantizeMethod, rangeX: float64(g_windowWidth), rangeY: float64(g_windowHeight)}
+		// Only the quantization uses the rest. Otherwise changing it would calculate the same colors again.
+		if g_quantizeMethod != QUANTIZE_NONE {
+			input.quantizeCount = g_quantizeColorCount
+			input.palette = g_cellPalette
+			input.adjustment = g_colorAdjustment
+		}
+		if colors, ok := exactCellColors(input, cells, triangles); ok {
+			cellColors = colors.cells
+			triangleColors = colors.triangles
+			g_quantizedPalette = colors.palette
+			g_exactColorsShown = true
+		}
+	} else {
+		cancelExactColors()
+	}
+
+	var edgeCellColors, edgeTriangleColors []mgl32.Vec4
+	if g_edgeColorSource == EDGE_COLOR_CELLS {
+		expectedRadius := calcExpectedRadius(g_delaunayPointCount, float64(g_windowWidth), float64(g_windowHeight), g_delaunayMargin)
+
+		centers := make([]sc.Vector, len(cells))
+		uvs := make([]mgl32.Vec2, len(cells))
+		for i, c := range cells {
+			centers[i] = c.Site
+			uvs[i] = positionUV(c.Site, float64(g_windowWidth), float64(g_windowHeight))
+		}
+		edgeCellColors = edgeFaceColors(centers, uvs, cellColors, expectedRadius, float64(g_windowWidth), float64(g_windowHeight))
+
+		centers = make([]sc.Vector, len(triangles))
+		uvs = make([]mgl32.Vec2, len(triangles))
+		for i, t := range triangles {
+			centers[i] = sc.Mult(sc.Add(t[0], sc.Add(t[1], t[2])), 1.0/3.0)
+			uvs[i] = triangleCenterUV(t, float64(g_windowWidth), float64(g_windowHeight))
+		}
+		edgeTriangleColors = edgeFaceColors(centers, uvs, triangleColors, expectedRadius, float64(g_windowWidth), float64(g_windowHeight))
+	}
+
+	relief := cellRelief()
+
+	g_voronoiTriangleGLBuffer = createVoronoiGLBuffer(g_shapedCells, cellColors, relief, g_sourceImage, float64(g_windowWidth), float64(g_windowHeight))
+	g_mosaicTriangleGLBuffer = createVoronoiGLBuffer(g_shapedTiles, cellColors, relief, g_sourceImage, float64(g_windowWidth), float64(g_windowHeight))
+	g_delaunayTriangleGLBuffer = createDelaunayGLBuffer(d, triangleColors, faceShading() == SHADING_GOURAUD, relief, g_sourceImage, float64(g_windowWidth), float64(g_windowHeight))
+	g_delaunayEdgesGLBuffer = createDelaunayEdgesGLBuffer(d, edgeTriangleColors, float64(g_windowWidth), float64(g_windowHeight))
+	g_voronoiEdgesGLBuffer = createVoronoiEdgesGLBuffer(v, edgeCellColors, float64(g_windowWidth), float64(g_windowHeight))
+
+	expectedRadius := calcExpectedRadius(g_delaunayPointCount, float64(g_windowWidth), float64(g_windowHeight), g_delaunayMargin)
+
+	vertices := make([]sc.Vector, len(d.Vertices))
+	for i, vertex := range d.Vertices {
+		vertices[i] = vertex.Pos
+	}
+	pointSizes := pointSizeFactors(vertices, cells, g_pointSizeMode, g_colorImage, g_colorSpace, expectedRadius, float64(g_windowWidth), float64(g_windowHeight))
+	g_delaunayPointsGLBuffer = createDelaunayPointsGLBuffer(d, pointSizes, float64(g_windowWidth), float64(g_windowHeight))
+
+	g_metricVoronoiOutdated = true
+}
+
 func defineModelMatrix(shader uint32, pos, scale mgl32.Vec3) {
 	matScale := mgl32.Scale3D(scale.X(), scale.Y(), scale.Z())
 	matTrans := mgl32.Translate3D(pos.X(), pos.Y(), pos.Z())
@@ -491,8 +1158,15 @@ func defineModelMatrix(shader uint32, pos, scale mgl32.Vec3) {
 
 // Defines the Model-View-Projection matrices for the shader.
 func defineMatrices(shader uint32) {
+	defineViewMatrices(shader, windowView())
+}
+
+// Projection of the part of the window that is shown by view.
+func defineViewMatrices(shader uint32, view RenderView) {
 	//projection := mgl32.Perspective(g_fovy, g_aspect, g_nearPlane, g_farPlane)
-	projection := mgl32.Ortho(-float32(g_windowWidth)/2, float32(g_windowWidth)/2, -float32(g_windowHeight)/2, float32(g_windowHeight)/2, g_nearPlane, g_farPlane)
+	left := view.X - float32(g_windowWidth)/2
+	bottom := view.Y - float32(g_windowHeight)/2
+	projection := mgl32.Ortho(left, left+view.Width, bottom, bottom+view.Height, g_nearPlane, g_farPlane)
 	camera := mgl32.LookAtV(mgl32.Vec3{0, 0, 1}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
 
 	viewProjection := projection.Mul4(camera)
@@ -500,27 +1174,197 @@ func defineMatrices(shader uint32) {
 	gl.UniformMatrix4fv(cameraUniform, 1, false, &viewProjection[0])
 }
 
+func setColorAdjustmentUniforms(shader uint32, a ColorAdjustment) {
+	gl.UseProgram(shader)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("saturation\x00")), a.Saturation)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("brightness\x00")), a.Brightness)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("contrast\x00")), a.Contrast)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("hueShift\x00")), a.HueShift)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("colorJitter\x00")), a.Jitter)
+	gl.Uniform1ui(gl.GetUniformLocation(shader, gl.Str("jitterSeed\x00")), a.Seed)
+	gl.Uniform1i(gl.GetUniformLocation(shader, gl.Str("posterizeLevels\x00")), int32(a.PosterizeLevels))
+}
+
+func setLightUniforms(shader uint32, l DirectionalLight) {
+	direction := l.Direction()
+	gl.UseProgram(shader)
+	gl.Uniform3fv(gl.GetUniformLocation(shader, gl.Str("lightDirection\x00")), 1, &direction[0])
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("ambient\x00")), l.Ambient)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("specular\x00")), l.Specular)
+	gl.Uniform1f(gl.GetUniformLocation(shader, gl.Str("shininess\x00")), l.Shininess)
+}
+
+// Draws simple lines in one color.
+func renderColoredLines(fbo uint32, buffer geo.ArrayGeometry, color *mgl32.Vec4, width float32) {
+	renderLineLayer(fbo, func() {
+		gl.UseProgram(g_delaunayEdgesShader)
+		gl.BindVertexArray(buffer.VertexBuffer)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("useExternalColor\x00")), 1)
+		gl.Uniform4fv(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("color\x00")), 1, &color[0])
+		gl.Uniform1f(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("lineWidth\x00")), width)
+		gl.DrawArrays(gl.LINES_ADJACENCY, 0, buffer.VertexCount)
+	})
+}
+
+// Which part of the window is rendered into how many pixels. Everything is positioned in window coordinates,
+// so an export at a higher resolution shows exactly the same picture as the window.
+type RenderView struct {
+	// In window coordinates, y points up.
+	X, Y, Width, Height float32
+	// Size of the viewport.
+	PixelWidth, PixelHeight int32
+}
+
+// The whole window at its own resolution.
+func windowView() RenderView {
+	return RenderView{0, 0, float32(g_windowWidth), float32(g_windowHeight), int32(g_windowWidth), int32(g_windowHeight)}
+}
+
+// Pixels per window pixel. Line widths and point sizes are scaled by it.
+func (v RenderView) Scale() float32 {
+	return float32(v.PixelWidth) / v.Width
+}
+
 func renderDelaunay() {
-	// Commented because of OpenGL 3.3 missmatch - Core in OpenGL 4.3. No Multisampling.
-	//gl.BindFramebuffer(gl.FRAMEBUFFER, g_sceneFboMS)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.ClearColor(0, 0, 0, 0)
+	// Without multisampling, the Fbo is 0 and we render into the window directly.
+	renderDelaunayView(windowView(), g_sceneMultisample.Fbo)
+	g_sceneMultisample.resolve(0)
+}
+
+// Renders view into the framebuffer fbo.
+func renderDelaunayView(view RenderView, fbo uint32) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	clearColor := mgl32.Vec4{0, 0, 0, 0}
+	if g_background == BACKGROUND_COLOR {
+		clearColor = g_backgroundColor
+	}
+	if g_renderMosaic && !g_groutUseImage {
+		// The grout is everything between the tiles. So we just clear with it.
+		clearColor = g_groutColor
+	}
+	// Premultiplied, like everything else in the framebuffer.
+	gl.ClearColor(clearColor[0]*clearColor[3], clearColor[1]*clearColor[3], clearColor[2]*clearColor[3], clearColor[3])
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-	gl.Viewport(0, 0, int32(g_windowWidth), int32(g_windowHeight))
+	gl.Viewport(0, 0, view.PixelWidth, view.PixelHeight)
 
 	gl.Disable(gl.DEPTH_TEST)
 
+	if g_lineLayer.Width != view.PixelWidth || g_lineLayer.Height != view.PixelHeight {
+		g_lineLayer.delete()
+		g_lineLayer = createLineLayerTarget(view.PixelWidth, view.PixelHeight)
+	}
+
+	scale := view.Scale()
+	for _, shader := range []uint32{g_delaunayTrianglesShader, g_stainedGlassShader, g_delaunayEdgesShader, g_delaunayPointsShader} {
+		gl.UseProgram(shader)
+		defineViewMatrices(shader, view)
+	}
+	// The fullscreen quad only shows the part of the images inside the view.
+	for _, shader := range []uint32{g_imageShader, g_worleyShader} {
+		gl.UseProgram(shader)
+		gl.Uniform4f(gl.GetUniformLocation(shader, gl.Str("viewRegion\x00")),
+			view.X/float32(g_windowWidth), view.Y/float32(g_windowHeight), view.Width/float32(g_windowWidth), view.Height/float32(g_windowHeight))
+	}
+
+	// All shaders write colors premultiplied with alpha.
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+
+	if (g_background == BACKGROUND_CHECKERBOARD && !g_exportingImage) || g_background == BACKGROUND_IMAGE {
+		gl.UseProgram(g_imageShader)
+		gl.BindVertexArray(g_fullscreenQuadGLBuffer.VertexBuffer)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_fullscreenQuadGLBuffer.IndexBuffer)
+		gl.ActiveTexture(gl.TEXTURE0)
+		gl.BindTexture(gl.TEXTURE_2D, g_delaunayTexture.TextureHandle)
+		gl.Uniform1f(gl.GetUniformLocation(g_imageShader, gl.Str("brightness\x00")), 1)
+		gl.Uniform1i(gl.GetUniformLocation(g_imageShader, gl.Str("checkerboard\x00")), boolToInt32(g_background == BACKGROUND_CHECKERBOARD))
+		gl.Uniform1f(gl.GetUniformLocation(g_imageShader, gl.Str("checkerSize\x00")), g_checkerboardSize)
+		gl.DrawElements(gl.TRIANGLES, g_fullscreenQuadGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		gl.Uniform1i(gl.GetUniformLocation(g_imageShader, gl.Str("checkerboard\x00")), 0)
+	}
+
+	// Every shader samples its colors from texture unit 0.
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, colorTextureHandle())
+
 	//expectedRadius := float32(g_windowWidth / g_windowWidth / math.Sqrt(float64(g_delaunayPointCount)))
 	expectedRadius := float32(calcExpectedRadius(g_delaunayPointCount, float64(g_windowWidth), float64(g_windowHeight), g_delaunayMargin))
 	expectedRadiusX := expectedRadius / float32(g_windowWidth)
 	expectedRadiusY := expectedRadius / float32(g_windowHeight)
 
+	// Exact colors were calculated on the CPU and are part of the vertex data.
+	useExactColor := boolToInt32(g_exactColorsShown)
+
+	for _, shader := range []uint32{g_delaunayTrianglesShader, g_stainedGlassShader, g_delaunayEdgesShader, g_worleyShader} {
+		gl.UseProgram(shader)
+		gl.Uniform1i(gl.GetUniformLocation(shader, gl.Str("colorSpace\x00")), int32(g_colorSpace))
+	}
+
+	// Quantized colors were already adjusted on the CPU.
+	useAdjustment := boolToInt32(g_colorAdjustment.Active() && (g_quantizeMethod == QUANTIZE_NONE || !g_exactColorsShown))
+	setColorAdjustmentUniforms(g_delaunayTrianglesShader, g_colorAdjustment)
+	setColorAdjustmentUniforms(g_stainedGlassShader, g_colorAdjustment)
+
+	// The face normals are only tilted with a relief.
+	useLight := boolToInt32(g_relief.Mode != RELIEF_NONE)
+	setLightUniforms(g_delaunayTrianglesShader, g_light)
+	setLightUniforms(g_stainedGlassShader, g_light)
+
+	gl.UseProgram(g_delaunayEdgesShader)
+	gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("edgeColorSource\x00")), int32(g_edgeColorSource))
+	gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("edgeBlend\x00")), int32(g_edgeBlend))
+	gl.Uniform2f(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("viewportSize\x00")), float32(view.PixelWidth), float32(view.PixelHeight))
+	gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("lineJoin\x00")), int32(g_lineJoin))
+
 	if g_renderTriangles {
 		gl.UseProgram(g_delaunayTrianglesShader)
 		gl.BindVertexArray(g_delaunayTriangleGLBuffer.VertexBuffer)
 		gl.Uniform1f(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("expectedRadiusX\x00")), expectedRadiusX)
 		gl.Uniform1f(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("expectedRadiusY\x00")), expectedRadiusY)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), useExactColor)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("adjustColors\x00")), useAdjustment)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), useLight)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("interpolateInColorSpace\x00")), boolToInt32(faceShading() == SHADING_GOURAUD))
 		gl.DrawArrays(gl.TRIANGLES, 0, g_delaunayTriangleGLBuffer.VertexCount)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("adjustColors\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("interpolateInColorSpace\x00")), 0)
+	}
+
+	if g_renderMetricVoronoi {
+		if g_metricVoronoiOutdated {
+			gl.DeleteTextures(1, &g_metricVoronoiTexture)
+			labels := CreateMetricVoronoiLabels(g_worleyGrid, g_voronoiMetric, g_minkowskiExponent, g_windowWidth, g_windowHeight, float64(g_windowWidth), float64(g_windowHeight))
+			g_metricVoronoiColors = metricVoronoiColors(labels, len(g_worleyGrid.Sites), g_colorImage, g_colorSpace, g_windowWidth, g_windowHeight)
+			g_metricVoronoiTexture = createRGBATexture(paintMetricVoronoi(labels, g_metricVoronoiColors, g_windowWidth, g_windowHeight))
+			g_metricVoronoiOutdated = false
+		}
+
+		gl.UseProgram(g_imageShader)
+		gl.BindVertexArray(g_fullscreenQuadGLBuffer.VertexBuffer)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_fullscreenQuadGLBuffer.IndexBuffer)
+		gl.ActiveTexture(gl.TEXTURE0)
+		gl.Uniform1f(gl.GetUniformLocation(g_imageShader, gl.Str("brightness\x00")), 1)
+
+		if view == windowView() {
+			gl.BindTexture(gl.TEXTURE_2D, g_metricVoronoiTexture)
+			gl.DrawElements(gl.TRIANGLES, g_fullscreenQuadGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		} else {
+			// Export tiles get their own labels at their own resolution, so the cell borders stay sharp.
+			// The texture covers exactly the view.
+			w, h := int(view.PixelWidth), int(view.PixelHeight)
+			labels := CreateMetricVoronoiRegionLabels(g_worleyGrid, g_voronoiMetric, g_minkowskiExponent, w, h,
+				float64(view.X), float64(view.Y), float64(view.Width), float64(view.Height))
+			texture := createRGBATexture(paintMetricVoronoi(labels, g_metricVoronoiColors, w, h))
+			gl.Uniform4f(gl.GetUniformLocation(g_imageShader, gl.Str("viewRegion\x00")), 0, 0, 1, 1)
+			gl.BindTexture(gl.TEXTURE_2D, texture)
+			gl.DrawElements(gl.TRIANGLES, g_fullscreenQuadGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+			gl.DeleteTextures(1, &texture)
+			gl.Uniform4f(gl.GetUniformLocation(g_imageShader, gl.Str("viewRegion\x00")),
+				view.X/float32(g_windowWidth), view.Y/float32(g_windowHeight), view.Width/float32(g_windowWidth), view.Height/float32(g_windowHeight))
+		}
+		gl.BindTexture(gl.TEXTURE_2D, colorTextureHandle())
 	}
 
 	if g_renderVoronoiCells {
@@ -528,50 +1372,163 @@ func renderDelaunay() {
 		gl.BindVertexArray(g_voronoiTriangleGLBuffer.VertexBuffer)
 		gl.Uniform1f(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("expectedRadiusX\x00")), expectedRadiusX)
 		gl.Uniform1f(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("expectedRadiusY\x00")), expectedRadiusY)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), useExactColor)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("adjustColors\x00")), useAdjustment)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), useLight)
+		gl.DrawArrays(gl.TRIANGLES, 0, g_voronoiTriangleGLBuffer.VertexCount)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("adjustColors\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), 0)
+	}
+
+	if g_renderPaletteCells {
+		gl.UseProgram(g_delaunayTrianglesShader)
+		gl.BindVertexArray(g_paletteTriangleGLBuffer.VertexBuffer)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), 1)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), useLight)
+		gl.DrawArrays(gl.TRIANGLES, 0, g_paletteTriangleGLBuffer.VertexCount)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), 0)
+	}
+
+	if g_renderWorley {
+		gl.UseProgram(g_worleyShader)
+		gl.BindVertexArray(g_fullscreenQuadGLBuffer.VertexBuffer)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_fullscreenQuadGLBuffer.IndexBuffer)
+		gl.ActiveTexture(gl.TEXTURE1)
+		gl.BindTexture(gl.TEXTURE_2D, g_worleyGridTexture)
+		gl.ActiveTexture(gl.TEXTURE2)
+		gl.BindTexture(gl.TEXTURE_2D, g_worleySiteTexture)
+		gl.ActiveTexture(gl.TEXTURE0)
+		gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("siteTextureWidth\x00")), g_siteTextureWidth)
+		gl.Uniform2i(gl.GetUniformLocation(g_worleyShader, gl.Str("gridSize\x00")), int32(g_worleyGrid.Width), int32(g_worleyGrid.Height))
+		gl.Uniform1f(gl.GetUniformLocation(g_worleyShader, gl.Str("cellSize\x00")), float32(g_worleyGrid.CellSize))
+		gl.Uniform2f(gl.GetUniformLocation(g_worleyShader, gl.Str("windowSize\x00")), float32(g_windowWidth), float32(g_windowHeight))
+		gl.Uniform2f(gl.GetUniformLocation(g_worleyShader, gl.Str("viewOrigin\x00")), view.X, view.Y)
+		gl.Uniform1f(gl.GetUniformLocation(g_worleyShader, gl.Str("viewScale\x00")), scale)
+		gl.Uniform1f(gl.GetUniformLocation(g_worleyShader, gl.Str("expectedRadius\x00")), expectedRadius)
+		gl.Uniform1f(gl.GetUniformLocation(g_worleyShader, gl.Str("expectedRadiusX\x00")), expectedRadiusX)
+		gl.Uniform1f(gl.GetUniformLocation(g_worleyShader, gl.Str("expectedRadiusY\x00")), expectedRadiusY)
+		gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("mode\x00")), int32(g_worleyMode))
+		gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("invert\x00")), boolToInt32(g_worleyInvert))
+		gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("modulateColor\x00")), boolToInt32(g_worleyModulate))
+		gl.DrawElements(gl.TRIANGLES, g_fullscreenQuadGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	}
+
+	if g_renderMosaic {
+		if g_groutUseImage {
+			// A darkened version of the image itself shines through the gaps.
+			gl.UseProgram(g_imageShader)
+			gl.BindVertexArray(g_fullscreenQuadGLBuffer.VertexBuffer)
+			gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_fullscreenQuadGLBuffer.IndexBuffer)
+			gl.Uniform1f(gl.GetUniformLocation(g_imageShader, gl.Str("brightness\x00")), g_groutImageBrightness)
+			gl.DrawElements(gl.TRIANGLES, g_fullscreenQuadGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		}
+
+		gl.UseProgram(g_delaunayTrianglesShader)
+		gl.BindVertexArray(g_mosaicTriangleGLBuffer.VertexBuffer)
+		gl.Uniform1f(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("expectedRadiusX\x00")), expectedRadiusX)
+		gl.Uniform1f(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("expectedRadiusY\x00")), expectedRadiusY)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), useExactColor)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("adjustColors\x00")), useAdjustment)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), useLight)
+		gl.DrawArrays(gl.TRIANGLES, 0, g_mosaicTriangleGLBuffer.VertexCount)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useVertexColor\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("adjustColors\x00")), 0)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("lightFaces\x00")), 0)
+	}
+
+	if g_renderStainedGlass {
+		// The glass: Voronoi cells with light shining through.
+		gl.UseProgram(g_stainedGlassShader)
+		gl.BindVertexArray(g_voronoiTriangleGLBuffer.VertexBuffer)
+		gl.Uniform1f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("expectedRadiusX\x00")), expectedRadiusX)
+		gl.Uniform1f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("expectedRadiusY\x00")), expectedRadiusY)
+		gl.Uniform1i(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("useVertexColor\x00")), useExactColor)
+		gl.Uniform1i(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("adjustColors\x00")), useAdjustment)
+		gl.Uniform1i(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("lightFaces\x00")), useLight)
+		gl.Uniform2f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("windowSize\x00")), float32(g_windowWidth), float32(g_windowHeight))
+		gl.Uniform1f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("cellRadius\x00")), expectedRadius)
+		gl.Uniform1f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("glow\x00")), g_glassGlow)
+		gl.Uniform1f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("glassTexture\x00")), g_glassTexture)
+		gl.Uniform1f(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("glassSaturation\x00")), g_glassSaturation)
 		gl.DrawArrays(gl.TRIANGLES, 0, g_voronoiTriangleGLBuffer.VertexCount)
 
+		// The lead came between the glass pieces. All of it has the same depth, so with the depth test
+		// the overlapping triangles only write every pixel once.
+		gl.Enable(gl.DEPTH_TEST)
+		gl.DepthFunc(gl.LESS)
+		gl.UseProgram(g_delaunayTrianglesShader)
+		gl.BindVertexArray(g_leadingGLBuffer.VertexBuffer)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useExternalColor\x00")), 1)
+		gl.Uniform4fv(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("color\x00")), 1, &g_leadingColor[0])
+		gl.DrawArrays(gl.TRIANGLES, 0, g_leadingGLBuffer.VertexCount)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useExternalColor\x00")), 0)
+		gl.Disable(gl.DEPTH_TEST)
+	}
+
+	if g_renderAlphaShape && g_renderAlphaShapeFill {
+		gl.UseProgram(g_delaunayTrianglesShader)
+		gl.BindVertexArray(g_alphaShapeTriangleGLBuffer.VertexBuffer)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useExternalColor\x00")), 1)
+		gl.Uniform4fv(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("color\x00")), 1, &g_alphaShapeColor[0])
+		gl.DrawArrays(gl.TRIANGLES, 0, g_alphaShapeTriangleGLBuffer.VertexCount)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("useExternalColor\x00")), 0)
 	}
 
 	if g_renderLines {
-		gl.UseProgram(g_delaunayEdgesShader)
-		gl.BindVertexArray(g_delaunayEdgesGLBuffer.VertexBuffer)
-		gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("useExternalColor\x00")), g_useExternalColor)
-		gl.Uniform3fv(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("color\x00")), 1, &g_delaunayLineColor[0])
-		gl.DrawArrays(gl.LINES, 0, g_delaunayEdgesGLBuffer.VertexCount)
+		renderLineLayer(fbo, func() {
+			gl.UseProgram(g_delaunayEdgesShader)
+			gl.BindVertexArray(g_delaunayEdgesGLBuffer.VertexBuffer)
+			gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("useExternalColor\x00")), g_useExternalColor)
+			gl.Uniform4fv(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("color\x00")), 1, &g_delaunayLineColor[0])
+			gl.Uniform1f(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("lineWidth\x00")), g_delaunayLineWidth*scale)
+			gl.DrawArrays(gl.LINES_ADJACENCY, 0, g_delaunayEdgesGLBuffer.VertexCount)
+		})
 	}
 
 	if g_renderPoints {
 		gl.UseProgram(g_delaunayPointsShader)
 		gl.BindVertexArray(g_delaunayPointsGLBuffer.VertexBuffer)
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_delaunayPointsGLBuffer.IndexBuffer)
 		gl.Uniform1i(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("useExternalColor\x00")), g_useExternalColor)
-		gl.Uniform3fv(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("color\x00")), 1, &g_pointColor[0])
-		gl.DrawElements(gl.POINTS, g_delaunayPointsGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		gl.Uniform4fv(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("color\x00")), 1, &g_pointColor[0])
+		gl.Uniform1f(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("pointSize\x00")), g_pointSize*scale)
+		gl.Uniform1i(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("pointShape\x00")), int32(g_pointShape))
+		gl.Uniform2f(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("viewportSize\x00")), float32(view.PixelWidth), float32(view.PixelHeight))
+		gl.DrawArrays(gl.POINTS, 0, g_delaunayPointsGLBuffer.VertexCount)
 	}
 
 	if g_renderConvexHull {
-		gl.UseProgram(g_delaunayEdgesShader)
-		gl.BindVertexArray(g_convexHullGLBuffer.VertexBuffer)
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_convexHullGLBuffer.IndexBuffer)
-		gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("useExternalColor\x00")), 1)
-		gl.Uniform3fv(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("color\x00")), 1, &g_chColor[0])
-		gl.DrawElements(gl.LINE_STRIP, g_convexHullGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		renderColoredLines(fbo, g_convexHullGLBuffer, &g_chColor, g_chLineWidth*scale)
 	}
 
 	if g_renderVoronoiEdges {
-		gl.UseProgram(g_delaunayEdgesShader)
-		gl.BindVertexArray(g_voronoiEdgesGLBuffer.VertexBuffer)
-		gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("useExternalColor\x00")), 1)
-		gl.Uniform3fv(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("color\x00")), 1, &g_voronoiLineColor[0])
-		gl.DrawArrays(gl.LINES, 0, g_voronoiEdgesGLBuffer.VertexCount)
+		renderLineLayer(fbo, func() {
+			gl.UseProgram(g_delaunayEdgesShader)
+			gl.BindVertexArray(g_voronoiEdgesGLBuffer.VertexBuffer)
+			gl.Uniform1i(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("useExternalColor\x00")), g_useExternalColor)
+			gl.Uniform4fv(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("color\x00")), 1, &g_voronoiLineColor[0])
+			gl.Uniform1f(gl.GetUniformLocation(g_delaunayEdgesShader, gl.Str("lineWidth\x00")), g_voronoiLineWidth*scale)
+			gl.DrawArrays(gl.LINES_ADJACENCY, 0, g_voronoiEdgesGLBuffer.VertexCount)
+		})
 	}
 
-	// Commented because of OpenGL 3.3 missmatch - Core in OpenGL 4.3. No Multisampling.
-	// Multisampling
-	//gl.BindFramebuffer(gl.READ_FRAMEBUFFER, g_sceneFboMS)
-	//gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
-	//gl.DrawBuffer(gl.BACK)
-	//gl.BlitFramebuffer(0, 0, int32(g_windowWidth), int32(g_windowHeight), 0, 0, int32(g_windowWidth), int32(g_windowHeight), gl.COLOR_BUFFER_BIT|gl.DEPTH_BUFFER_BIT, gl.NEAREST)
+	if g_renderAlphaShape {
+		renderColoredLines(fbo, g_alphaShapeEdgesGLBuffer, &g_alphaShapeColor, g_alphaShapeLineWidth*scale)
+	}
+
+	if g_renderKNN {
+		renderColoredLines(fbo, g_knnGLBuffer, &g_knnColor, g_knnLineWidth*scale)
+	}
+	if g_renderGabriel {
+		renderColoredLines(fbo, g_gabrielGLBuffer, &g_gabrielColor, g_gabrielLineWidth*scale)
+	}
+	if g_renderRNG {
+		renderColoredLines(fbo, g_rngGLBuffer, &g_rngColor, g_rngLineWidth*scale)
+	}
+	if g_renderMST {
+		renderColoredLines(fbo, g_mstGLBuffer, &g_mstColor, g_mstLineWidth*scale)
+	}
 
 }
 
@@ -579,12 +1536,10 @@ func prepareGLForNewTexture(imagePath string) {
 
 	// OpenGL should silently ignore if the texture doesn't exist!
 	gl.DeleteTextures(1, &g_delaunayTexture.TextureHandle)
-	gl.DeleteTextures(1, &g_sceneColorTexMS)
-	gl.DeleteTextures(1, &g_sceneDepthTexMS)
-	// Commented because of OpenGL 3.3 missmatch - Core in OpenGL 4.3. No Multisampling.
-	//gl.DeleteFramebuffers(1, &g_sceneFboMS)
 
 	g_delaunayTexture = mtgl.CreateImageTexture(imagePath, false)
+	g_sourceImage = loadSourceImage(imagePath)
+	updateColorImage()
 
 	g_windowWidth = int(g_delaunayTexture.TextureSize.X())
 	g_windowHeight = int(g_delaunayTexture.TextureSize.Y())
@@ -601,8 +1556,7 @@ func prepareGLForNewTexture(imagePath string) {
 
 	g_window.SetSize(g_windowWidth, g_windowHeight)
 
-	// Commented because of OpenGL 3.3 missmatch - Core in OpenGL 4.3. No Multisampling.
-	//g_sceneFboMS = mtgl.CreateFbo(&g_sceneColorTexMS, &g_sceneDepthTexMS, int32(g_windowWidth), int32(g_windowHeight), false, 16, false, 1)
+	updateMultisampleTarget()
 
 	gl.UseProgram(g_delaunayTrianglesShader)
 	defineMatrices(g_delaunayTrianglesShader)
@@ -611,6 +1565,13 @@ func prepareGLForNewTexture(imagePath string) {
 	gl.BindTexture(gl.TEXTURE_2D, g_delaunayTexture.TextureHandle)
 	gl.Uniform1i(gl.GetUniformLocation(g_delaunayTrianglesShader, gl.Str("imageTexture\x00")), 0)
 
+	gl.UseProgram(g_stainedGlassShader)
+	defineMatrices(g_stainedGlassShader)
+	defineModelMatrix(g_stainedGlassShader, mgl32.Vec3{-float32(g_windowWidth) / 2, -float32(g_windowHeight) / 2, 0}, mgl32.Vec3{1, 1, 1})
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, g_delaunayTexture.TextureHandle)
+	gl.Uniform1i(gl.GetUniformLocation(g_stainedGlassShader, gl.Str("imageTexture\x00")), 0)
+
 	gl.UseProgram(g_delaunayEdgesShader)
 	defineMatrices(g_delaunayEdgesShader)
 	defineModelMatrix(g_delaunayEdgesShader, mgl32.Vec3{-float32(g_windowWidth) / 2, -float32(g_windowHeight) / 2, 0}, mgl32.Vec3{1, 1, 1})
@@ -625,12 +1586,86 @@ func prepareGLForNewTexture(imagePath string) {
 	gl.BindTexture(gl.TEXTURE_2D, g_delaunayTexture.TextureHandle)
 	gl.Uniform1i(gl.GetUniformLocation(g_delaunayPointsShader, gl.Str("imageTexture\x00")), 0)
 
+	gl.UseProgram(g_imageShader)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, g_delaunayTexture.TextureHandle)
+	gl.Uniform1i(gl.GetUniformLocation(g_imageShader, gl.Str("imageTexture\x00")), 0)
+
+	gl.UseProgram(g_worleyShader)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, g_delaunayTexture.TextureHandle)
+	gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("imageTexture\x00")), 0)
+	gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("gridTexture\x00")), 1)
+	gl.Uniform1i(gl.GetUniformLocation(g_worleyShader, gl.Str("siteTexture\x00")), 2)
+
 	gl.UseProgram(0)
 }
 
+// The multisample framebuffer must always have the size of the window.
+func updateMultisampleTarget() {
+	g_sceneMultisample.delete()
+	g_sceneMultisample = createMultisampleTarget(int32(g_windowWidth), int32(g_windowHeight), g_multisampleCount)
+}
+
+// Aligns the color source image to the current source image and uploads it.
+// Without a color source image, the source image is used for the colors as well.
+func updateColorImage() {
+	gl.DeleteTextures(1, &g_colorTexture)
+	g_colorTexture = 0
+
+	// No image loaded yet.
+	if g_sourceImage == nil {
+		return
+	}
+	if g_colorSourceImage == nil {
+		g_colorImage = g_sourceImage
+		return
+	}
+	g_colorImage = alignColorImage(g_colorSourceImage, g_sourceImage.Rect.Dx(), g_sourceImage.Rect.Dy(), g_colorImageAlignment)
+	g_colorTexture = createRGBATexture(g_colorImage)
+}
+
+func colorTextureHandle() uint32 {
+	if g_colorTexture != 0 {
+		return g_colorTexture
+	}
+	return g_delaunayTexture.TextureHandle
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func SetPointDistributionMethod(method int) {
 	g_delaunayDistribution = method
 }
+func SetAdaptiveThreshold(threshold float64) {
+	g_adaptiveThreshold = threshold
+}
+func SetAdaptiveMaxDepth(depth int) {
+	g_adaptiveMaxDepth = depth
+}
+func SetAdaptivePointBudget(budget int) {
+	g_adaptivePointBudget = budget
+}
+func SetOptimizeTriangles(optimize bool) {
+	g_optimizeTriangles = optimize
+}
+func SetOptimizerIterations(iterations int) {
+	g_optimizerIterations = iterations
+}
+func SetOptimizerTimeBudget(budget time.Duration) {
+	g_optimizerTimeBudget = budget
+}
+func SetOptimizerProgressCallback(progress func(step int, done float64)) {
+	g_optimizerProgress = progress
+}
+func SetAdaptiveProgressCallback(progress func(level, maxDepth, pointCount int)) {
+	g_adaptiveProgress = progress
+}
 
 func IncreasePointCount() {
 	g_delaunayPointCount *= 2
@@ -660,6 +1695,188 @@ func SetRenderPoints(show bool) {
 func SetRenderConvexHull(show bool) {
 	g_renderConvexHull = show
 }
+func SetRenderStainedGlass(show bool) {
+	g_renderStainedGlass = show
+}
+func SetLeadingWidth(width float64) {
+	g_leadingWidth = width
+}
+func SetLeadingColor(r, g, b, a float64) {
+	g_leadingColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetGlassGlow(glow float32) {
+	g_glassGlow = glow
+}
+func SetGlassTexture(strength float32) {
+	g_glassTexture = strength
+}
+func SetGlassSaturation(saturation float32) {
+	g_glassSaturation = saturation
+}
+func SetRenderMosaic(show bool) {
+	g_renderMosaic = show
+}
+func SetMosaicGap(gap float64) {
+	g_mosaicGap = gap
+}
+func SetMosaicGapVariation(variation float64) {
+	g_mosaicGapVariation = variation
+}
+func SetGroutUseImage(useImage bool) {
+	g_groutUseImage = useImage
+}
+func SetCellShape(shape int) {
+	g_cellShape = shape
+}
+func SetCellCornerRadius(radius float64) {
+	g_cellCornerRadius = radius
+}
+func SetCellSmoothIterations(iterations int) {
+	g_cellSmoothIterations = iterations
+}
+func SetRenderPaletteCells(show bool) {
+	g_renderPaletteCells = show
+}
+func SetCellPalette(palette []mgl32.Vec4) {
+	g_cellPalette = palette
+}
+func SetPaletteColoringMethod(method int) {
+	g_paletteColoringMethod = method
+}
+func SetPaletteBalanced(balanced bool) {
+	g_paletteBalanced = balanced
+}
+func SetCellColorMode(mode int) {
+	g_cellColorMode = mode
+}
+
+// The shader adjusts the colors, only quantized colors and edge colors from the cells
+// are adjusted on the CPU and must be calculated again.
+func colorAdjustmentChanged() {
+	if g_quantizeMethod != QUANTIZE_NONE || g_edgeColorSource == EDGE_COLOR_CELLS {
+		ReadyForRecolor(true)
+	}
+}
+func SetSaturation(saturation float32) {
+	g_colorAdjustment.Saturation = saturation
+	colorAdjustmentChanged()
+}
+func SetBrightness(brightness float32) {
+	g_colorAdjustment.Brightness = brightness
+	colorAdjustmentChanged()
+}
+func SetContrast(contrast float32) {
+	g_colorAdjustment.Contrast = contrast
+	colorAdjustmentChanged()
+}
+func SetHueShift(degrees float32) {
+	g_colorAdjustment.HueShift = mgl32.DegToRad(degrees)
+	colorAdjustmentChanged()
+}
+func SetColorJitter(jitter float32) {
+	g_colorAdjustment.Jitter = jitter
+	colorAdjustmentChanged()
+}
+func SetColorJitterSeed(seed uint32) {
+	g_colorAdjustment.Seed = seed
+	colorAdjustmentChanged()
+}
+func SetPosterizeLevels(levels int) {
+	g_colorAdjustment.PosterizeLevels = levels
+	colorAdjustmentChanged()
+}
+func SetEdgeColorSource(source int) {
+	g_edgeColorSource = source
+}
+func SetEdgeBlend(blend int) {
+	g_edgeBlend = blend
+}
+func SetReliefMode(mode int) {
+	g_relief.Mode = mode
+}
+func SetReliefHeight(height float64) {
+	g_relief.Height = height
+}
+func SetReliefSeed(seed uint32) {
+	g_relief.Seed = seed
+}
+func SetLightAzimuth(degrees float32) {
+	g_light.Azimuth = degrees
+}
+func SetLightElevation(degrees float32) {
+	g_light.Elevation = degrees
+}
+func SetLightAmbient(ambient float32) {
+	g_light.Ambient = ambient
+}
+func SetLightSpecular(specular float32) {
+	g_light.Specular = specular
+}
+func SetLightShininess(shininess float32) {
+	g_light.Shininess = shininess
+}
+func SetColorSpace(space int) {
+	g_colorSpace = space
+}
+func SetFaceShading(shading int) {
+	g_faceShading = shading
+}
+func SetQuantizeMethod(method int) {
+	g_quantizeMethod = method
+}
+func SetQuantizeColorCount(count int) {
+	g_quantizeColorCount = count
+}
+func SetPaletteFileExtension(extension string) {
+	g_paletteFileExtension = extension
+}
+func SetRenderAlphaShape(show bool) {
+	g_renderAlphaShape = show
+}
+func SetRenderAlphaShapeFill(fill bool) {
+	g_renderAlphaShapeFill = fill
+}
+func SetAlphaShapeFactor(factor float64) {
+	g_alphaShapeFactor = factor
+}
+func SetRenderMST(show bool) {
+	g_renderMST = show
+}
+func SetRenderGabriel(show bool) {
+	g_renderGabriel = show
+}
+func SetRenderRNG(show bool) {
+	g_renderRNG = show
+}
+func SetRenderKNN(show bool) {
+	g_renderKNN = show
+}
+func SetKNNCount(k int) {
+	g_knnCount = k
+}
+func SetRenderMetricVoronoi(show bool) {
+	g_renderMetricVoronoi = show
+}
+func SetVoronoiMetric(metric int) {
+	g_voronoiMetric = metric
+	g_metricVoronoiOutdated = true
+}
+func SetMinkowskiExponent(exponent float64) {
+	g_minkowskiExponent = exponent
+	g_metricVoronoiOutdated = true
+}
+func SetRenderWorley(show bool) {
+	g_renderWorley = show
+}
+func SetWorleyMode(mode int) {
+	g_worleyMode = mode
+}
+func SetWorleyInvert(invert bool) {
+	g_worleyInvert = invert
+}
+func SetWorleyModulate(modulate bool) {
+	g_worleyModulate = modulate
+}
 func SetUseExternalColor(useExternalColor bool) {
 	if useExternalColor {
 		g_useExternalColor = 1
@@ -670,13 +1887,50 @@ func SetUseExternalColor(useExternalColor bool) {
 func SetNewImage(path string) {
 	prepareGLForNewTexture(path)
 }
+
+// An empty path removes the color image again.
+func SetColorImage(path string) {
+	g_colorSourceImage = nil
+	if path != "" {
+		g_colorSourceImage = loadSourceImage(path)
+	}
+	updateColorImage()
+}
+func SetColorImageFit(fit int) {
+	g_colorImageAlignment.Fit = fit
+	updateColorImage()
+}
+func SetColorImageScale(scale float64) {
+	g_colorImageAlignment.Scale = scale
+	updateColorImage()
+}
+func SetColorImageOffsetX(offset float64) {
+	g_colorImageAlignment.OffsetX = offset
+	updateColorImage()
+}
+func SetColorImageOffsetY(offset float64) {
+	g_colorImageAlignment.OffsetY = offset
+	updateColorImage()
+}
+func SetExportWidth(width int) {
+	g_exportWidth = width
+}
+func SetExportDPI(dpi int) {
+	g_exportDPI = dpi
+}
 func SaveImage(path string) {
 
+	// Rendered again without the checkerboard preview. The back buffer is not shown, so the window does not flicker.
+	// With multisampling, the back buffer holds the resolved image.
+	g_exportingImage = true
+	renderDelaunay()
+	g_exportingImage = false
+
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 
 	pixels := make([]byte, g_windowWidth*g_windowHeight*4)
 	pixelsFlipped := make([]byte, g_windowWidth*g_windowHeight*4)
-	gl.ReadBuffer(gl.FRONT)
+	gl.ReadBuffer(gl.BACK)
 	gl.ReadPixels(0, 0, int32(g_windowWidth), int32(g_windowHeight), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
 
 	// Flipping the Y-Axis because OpenGL's y-axis is mirrored compared to normal images.
@@ -690,9 +1944,53 @@ func SaveImage(path string) {
 		}
 	}
 
+	// The framebuffer is premultiplied just like image.RGBA. The PNG encoder writes real (straight) alpha.
 	img := image.NewRGBA(image.Rect(0, 0, g_windowWidth, g_windowHeight))
 	img.Pix = pixelsFlipped
 
+	writePNG(path, img)
+
+	// The back buffer may be swapped in next and must show the preview again.
+	ReadyForRender(true)
+
+	writeQuantizedPalette(path)
+}
+
+// Size of exported images. The height follows the aspect ratio of the window, if the width is not the source resolution.
+func exportSize() (int, int) {
+	if g_exportWidth <= 0 {
+		return g_sourceImage.Rect.Dx(), g_sourceImage.Rect.Dy()
+	}
+	return g_exportWidth, int(float64(g_exportWidth)*float64(g_windowHeight)/float64(g_windowWidth) + 0.5)
+}
+
+// Renders the current tessellation offscreen at the export size and writes it as PNG.
+// The export DPI is stored in the PNG if it is not 0.
+func ExportImage(path string) {
+	width, height := exportSize()
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	if err := writePNGWithDPI(path, renderOffscreen(width, height), g_exportDPI); err != nil {
+		fmt.Printf("error when exporting the image: %v\n", err)
+	}
+
+	ReadyForRender(true)
+
+	writeQuantizedPalette(path)
+}
+
+// The palette is written next to every saved image while quantization is active.
+func writeQuantizedPalette(imagePath string) {
+	if len(g_quantizedPalette) > 0 {
+		palettePath := strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + g_paletteFileExtension
+		if err := WritePalette(palettePath, g_quantizedPalette); err != nil {
+			fmt.Printf("error when writing the palette: %v\n", err)
+		}
+	}
+}
+func writePNG(path string, img image.Image) {
 	file, err := os.Create(path)
 	defer file.Close()
 	if err != nil {
@@ -702,7 +2000,39 @@ func SaveImage(path string) {
 	if err != nil {
 		fmt.Printf("error when saving an image: %v\n", err)
 	}
+}
+
+// Measures the current triangulation and writes the report as CSV or JSON (depending on the file extension).
+func ExportStatistics(path string) {
+	d, v := g_delaunay, g_voronoi
+	img := g_colorImage
+	space := g_colorSpace
+	rangeX, rangeY := float64(g_windowWidth), float64(g_windowHeight)
+
+	go func() {
+		CreateStatisticsReport(d, v, img, space, rangeX, rangeY).Write(path)
+	}()
+}
 
+// Renders the Worley noise on the CPU at the export size instead of reading it back from the window.
+// The rendering happens in the background, everything it needs is copied first.
+func ExportWorleyImage(path string) {
+	grid := g_worleyGrid
+	img := g_colorImage
+	mode, invert, modulate, space := g_worleyMode, g_worleyInvert, g_worleyModulate, g_colorSpace
+	width, height := exportSize()
+	dpi := g_exportDPI
+	rangeX, rangeY := float64(g_windowWidth), float64(g_windowHeight)
+	expectedRadius := calcExpectedRadius(g_delaunayPointCount, rangeX, rangeY, g_delaunayMargin)
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	go func() {
+		if err := writePNGWithDPI(path, RenderWorleyImage(grid, img, mode, invert, modulate, space, expectedRadius, width, height, rangeX, rangeY), dpi); err != nil {
+			fmt.Printf("error when exporting the image: %v\n", err)
+		}
+	}()
 }
 func SetVoronoiLineColor(r, g, b, a float64) {
 	g_voronoiLineColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
@@ -716,6 +2046,70 @@ func SetPointColor(r, g, b, a float64) {
 func SetCHColor(r, g, b, a float64) {
 	g_chColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
 }
+func SetAlphaShapeColor(r, g, b, a float64) {
+	g_alphaShapeColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetMSTColor(r, g, b, a float64) {
+	g_mstColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetGabrielColor(r, g, b, a float64) {
+	g_gabrielColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetRNGColor(r, g, b, a float64) {
+	g_rngColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetKNNColor(r, g, b, a float64) {
+	g_knnColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetVoronoiLineWidth(width float32) {
+	g_voronoiLineWidth = width
+}
+func SetDelaunayLineWidth(width float32) {
+	g_delaunayLineWidth = width
+}
+func SetCHLineWidth(width float32) {
+	g_chLineWidth = width
+}
+func SetAlphaShapeLineWidth(width float32) {
+	g_alphaShapeLineWidth = width
+}
+func SetMSTLineWidth(width float32) {
+	g_mstLineWidth = width
+}
+func SetGabrielLineWidth(width float32) {
+	g_gabrielLineWidth = width
+}
+func SetRNGLineWidth(width float32) {
+	g_rngLineWidth = width
+}
+func SetKNNLineWidth(width float32) {
+	g_knnLineWidth = width
+}
+func SetLineJoin(join int) {
+	g_lineJoin = join
+}
+func SetPointSize(size float32) {
+	g_pointSize = size
+}
+func SetPointShape(shape int) {
+	g_pointShape = shape
+}
+func SetPointSizeMode(mode int) {
+	g_pointSizeMode = mode
+}
+func SetMultisampleCount(samples int) {
+	g_multisampleCount = int32(samples)
+	updateMultisampleTarget()
+}
+func SetGroutColor(r, g, b, a float64) {
+	g_groutColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
+func SetBackground(background int) {
+	g_background = background
+}
+func SetBackgroundColor(r, g, b, a float64) {
+	g_backgroundColor = mgl32.Vec4{float32(r), float32(g), float32(b), float32(a)}
+}
 
 func CloseWindow() {
 	g_window.SetShouldClose(true)
@@ -724,6 +2118,9 @@ func CloseWindow() {
 func ReadyForRebuild(r bool) {
 	g_readyForRebuild = r
 }
+func ReadyForRecolor(r bool) {
+	g_readyForRecolor = r
+}
 func ReadyForRender(r bool) {
 	g_readyForRender = r
 }
@@ -763,9 +2160,6 @@ func mainLoop(window *glfw.Window) {
 	registerCallBacks(window)
 	glfw.SwapInterval(1)
 
-	gl.PointSize(5)
-	gl.Enable(gl.LINE_SMOOTH)
-
 	for !window.ShouldClose() {
 
 		g_currentTime = glfw.GetTime()
@@ -773,6 +2167,12 @@ func mainLoop(window *glfw.Window) {
 		if g_readyForRebuild {
 			recalculateDelaunayTriangulation()
 			g_readyForRebuild = false
+			g_readyForRecolor = false
+		}
+
+		if g_readyForRecolor {
+			recolorDelaunayTriangulation()
+			g_readyForRecolor = false
 		}
 
 		if g_readyForRender {
@@ -800,6 +2200,8 @@ func initGraphicContext() (*glfw.Window, error) {
 	glfw.WindowHint(glfw.ContextVersionMinor, 3)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	// Saved images keep their transparency.
+	glfw.WindowHint(glfw.AlphaBits, 8)
 	//glfw.WindowHint(glfw.Decorated, glfw.False)
 
 	window, err := glfw.CreateWindow(g_windowWidth, g_windowHeight, g_windowTitle, nil, nil)
@@ -832,18 +2234,38 @@ func InitializeRender(communication chan func(), closingChannel chan int, pointC
 	}
 
 	path := "./"
-	g_delaunayTrianglesShader, err = mtgl.NewProgram(path+"triangles.vert", "", "", "", path+"simple.frag")
+	g_delaunayTrianglesShader, err = newProgram(path+"triangles.vert", "", path+"simple.frag")
 	if err != nil {
 		panic(err)
 	}
-	g_delaunayEdgesShader, err = mtgl.NewProgram(path+"simple.vert", path+"edges.geo", "", "", path+"simple.frag")
+	g_stainedGlassShader, err = newProgram(path+"triangles.vert", "", path+"glass.frag")
 	if err != nil {
 		panic(err)
 	}
-	g_delaunayPointsShader, err = mtgl.NewProgram(path+"points.vert", "", "", "", path+"points.frag")
+	g_delaunayEdgesShader, err = newProgram(path+"simple.vert", path+"edges.geo", path+"lines.frag")
 	if err != nil {
 		panic(err)
 	}
+	g_delaunayPointsShader, err = newProgram(path+"points.vert", path+"points.geo", path+"points.frag")
+	if err != nil {
+		panic(err)
+	}
+	g_imageShader, err = newProgram(path+"image.vert", "", path+"image.frag")
+	if err != nil {
+		panic(err)
+	}
+
+	g_worleyShader, err = newProgram(path+"image.vert", "", path+"worley.frag")
+	if err != nil {
+		panic(err)
+	}
+
+	g_lineLayerShader, err = newProgram(path+"image.vert", "", path+"layer.frag")
+	if err != nil {
+		panic(err)
+	}
+
+	g_fullscreenQuadGLBuffer = geo.CreateFullscreenQuadGeometry()
 
 	g_delaunayPointCount = pointCount
 	g_controlCommunication = communication
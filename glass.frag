@@ -0,0 +1,51 @@
+#version 330
+
+in fData
+{
+    vec4 color;
+} g_in;
+
+// Set by triangles.vert.
+in vec2 siteOffset;
+in vec2 pixelPosition;
+
+// Expected cell radius in pixels.
+uniform float cellRadius;
+// Brightness difference between the center and the rim of a cell.
+uniform float glow;
+// Strength of the glass structure.
+uniform float glassTexture;
+uniform float glassSaturation;
+
+out vec4 colorOut;
+
+float hash(vec2 p) {
+    return fract(sin(dot(p, vec2(127.1, 311.7))) * 43758.5453);
+}
+
+// Smooth value noise in [-1,1].
+float valueNoise(vec2 p) {
+    vec2 i = floor(p);
+    vec2 f = fract(p);
+    vec2 u = f*f*(3.0 - 2.0*f);
+    float n = mix(mix(hash(i), hash(i + vec2(1, 0)), u.x),
+                  mix(hash(i + vec2(0, 1)), hash(i + vec2(1, 1)), u.x), u.y);
+    return 2.0*n - 1.0;
+}
+
+void main() {
+    vec3 c = g_in.color.rgb;
+
+    float luminance = dot(c, vec3(0.2126, 0.7152, 0.0722));
+    c = mix(vec3(luminance), c, glassSaturation);
+
+    // Light shines through the middle of the glass and less through the rim next to the lead.
+    float distance = clamp(length(siteOffset) / max(cellRadius, 1.0), 0.0, 1.0);
+    c *= mix(1.0 + glow, 1.0 - glow, distance*distance);
+
+    // Uneven, hand made glass: coarse waves and fine grain.
+    float structure = 0.7*valueNoise(pixelPosition / 12.0) + 0.3*valueNoise(pixelPosition / 3.0);
+    c *= 1.0 + glassTexture * structure;
+
+    colorOut = vec4(clamp(c, 0.0, 1.0) * g_in.color.a, g_in.color.a);
+}
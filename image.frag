@@ -0,0 +1,22 @@
+#version 330
+
+uniform sampler2D imageTexture;
+uniform float brightness;
+// Draws the usual transparency pattern instead of the image.
+uniform bool checkerboard;
+// Size of one checkerboard square in pixels.
+uniform float checkerSize;
+
+in vec2 vUV;
+out vec4 colorOut;
+
+void main() {
+    if (checkerboard) {
+        ivec2 square = ivec2(floor(gl_FragCoord.xy / checkerSize));
+        colorOut = vec4(vec3((square.x + square.y) % 2 == 0 ? 0.8 : 0.6), 1);
+        return;
+    }
+    // The texture is premultiplied, so is the output.
+    vec4 tex = texture(imageTexture, vUV);
+    colorOut = vec4(tex.rgb * brightness, tex.a);
+}
@@ -0,0 +1,18 @@
+#version 330
+
+layout (location = 0) in vec3 vertPos;
+layout (location = 1) in vec3 vertNormal;
+layout (location = 2) in vec2 vertUV;
+
+// The fullscreen quad is already in clip space. No matrices needed.
+
+// Part of the image that fills the viewport: offset and size in uv (v pointing up like the window).
+uniform vec4 viewRegion;
+
+out vec2 vUV;
+
+void main() {
+    gl_Position = vec4(vertPos.xy, 0, 1);
+    vec2 uv = viewRegion.xy + vertUV * viewRegion.zw;
+    vUV = vec2(uv.x, 1.0-uv.y);
+}
@@ -0,0 +1,131 @@
+// imageSampling
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	mtgl "github.com/MauriceGit/mtOpenGL"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// The same sample positions the triangles shader uses in sampleTextureRandom, in [0,1].
+var g_randomSampleOffsets = [...][2]float32{
+	{0.36123, 0.83771},
+	{0.47154, 0.44896},
+	{0.93110, 0.64977},
+	{0.15231, 0.46326},
+	{0.83720, 0.11699},
+	{0.30478, 0.06818},
+}
+
+// Loads the image a second time for everything that is calculated on the CPU.
+// Returns an empty 1x1 image if loading fails, so sampling never has to check for nil.
+func loadSourceImage(path string) *image.RGBA {
+	img, err := mtgl.LoadImage(path)
+	if err != nil {
+		fmt.Printf("error when loading the source image: %v\n", err)
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, img.RangeX(), img.RangeY()))
+	draw.Draw(rgba, rgba.Bounds(), img.Img, img.Img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// Works exactly like texture() in the shaders for our image texture (CLAMP_TO_EDGE, NEAREST).
+// uv is in texture space, so v = 0 is the first (top) row of the image.
+// Like the texture, the color is premultiplied with alpha.
+func sampleImage(img *image.RGBA, u, v float32) mgl32.Vec3 {
+	x, y := imageCoordinates(img, u, v)
+	return imagePixel(img, x, y)
+}
+
+// Like sampleImage, but the color is not premultiplied and alpha is the fourth component.
+func sampleImageAlpha(img *image.RGBA, u, v float32) mgl32.Vec4 {
+	x, y := imageCoordinates(img, u, v)
+	return imagePixelAlpha(img, x, y)
+}
+
+// The pixel texture() would return for uv.
+func imageCoordinates(img *image.RGBA, u, v float32) (int, int) {
+	w := img.Rect.Dx()
+	h := img.Rect.Dy()
+
+	x := int(u * float32(w))
+	y := int(v * float32(h))
+	if u < 0 {
+		x = 0
+	}
+	if v < 0 {
+		y = 0
+	}
+	if x >= w {
+		x = w - 1
+	}
+	if y >= h {
+		y = h - 1
+	}
+	return x, y
+}
+
+// Color of one pixel, x and y relative to the image bounds. image.RGBA is premultiplied with alpha, so is the color.
+func imagePixel(img *image.RGBA, x, y int) mgl32.Vec3 {
+	i := img.PixOffset(x+img.Rect.Min.X, y+img.Rect.Min.Y)
+	return mgl32.Vec3{float32(img.Pix[i]) / 255, float32(img.Pix[i+1]) / 255, float32(img.Pix[i+2]) / 255}
+}
+
+// Color of one pixel with alpha as the fourth component. The color is not premultiplied.
+func imagePixelAlpha(img *image.RGBA, x, y int) mgl32.Vec4 {
+	i := img.PixOffset(x+img.Rect.Min.X, y+img.Rect.Min.Y)
+	return unpremultiply(imagePixel(img, x, y), float32(img.Pix[i+3])/255)
+}
+
+// Fully transparent colors are black.
+func unpremultiply(c mgl32.Vec3, alpha float32) mgl32.Vec4 {
+	if alpha <= 0 {
+		return mgl32.Vec4{}
+	}
+	c = c.Mul(1 / alpha)
+	for i := 0; i < 3; i++ {
+		c[i] = float32(math.Min(1, float64(c[i])))
+	}
+	return c.Vec4(alpha)
+}
+
+// The color as it is stored in an image.RGBA (premultiplied).
+func premultipliedRGBA(c mgl32.Vec4) color.RGBA {
+	to8 := func(f float32) uint8 {
+		return uint8(math.Max(0, math.Min(255, float64(f)*255+0.5)))
+	}
+	return color.RGBA{to8(c[0] * c[3]), to8(c[1] * c[3]), to8(c[2] * c[3]), to8(c[3])}
+}
+
+// Pixels around the sample point that sampleImageBox averages, in every direction.
+const g_boxSampleRadius = 2
+
+// Average of the pixels in a small box around uv, in the color space. Not premultiplied, alpha is the mean alpha.
+func sampleImageBox(img *image.RGBA, u, v float32, space int) mgl32.Vec4 {
+	cx, cy := imageCoordinates(img, u, v)
+	samples := make([]mgl32.Vec4, 0, (2*g_boxSampleRadius+1)*(2*g_boxSampleRadius+1))
+	for y := cy - g_boxSampleRadius; y <= cy+g_boxSampleRadius; y++ {
+		for x := cx - g_boxSampleRadius; x <= cx+g_boxSampleRadius; x++ {
+			if x >= 0 && y >= 0 && x < img.Rect.Dx() && y < img.Rect.Dy() {
+				samples = append(samples, imagePixelAlpha(img, x, y))
+			}
+		}
+	}
+	return averageColorsAlpha(samples, space)
+}
+
+// CPU version of sampleTextureRandom from triangles.vert.
+func sampleImageRandom(img *image.RGBA, r, pos mgl32.Vec2, space int) mgl32.Vec4 {
+	samples := make([]mgl32.Vec4, len(g_randomSampleOffsets))
+	for i, o := range g_randomSampleOffsets {
+		samples[i] = sampleImageAlpha(img, pos.X()+r.X()*(2*o[0]-1), pos.Y()+r.Y()*(2*o[1]-1))
+	}
+	return averageColorsAlpha(samples, space)
+}
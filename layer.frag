@@ -0,0 +1,11 @@
+#version 330
+
+// One layer of lines, see lineLayer.go. It has the size of the viewport, so the pixel position is enough.
+uniform sampler2D layerTexture;
+
+out vec4 colorOut;
+
+void main() {
+    // Premultiplied, like everything else.
+    colorOut = texelFetch(layerTexture, ivec2(gl_FragCoord.xy), 0);
+}
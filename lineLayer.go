@@ -0,0 +1,87 @@
+// lineLayer
+package main
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Texture the lines of one layer are drawn into before they are blended over the scene.
+// The thick lines of a layer overlap where they meet. In the layer, every pixel keeps only the fragment that covers
+// it the most (lines.frag writes the coverage as depth). So lines with transparency get no darker spots at the
+// vertices, and lines of different colors do not mix where they overlap.
+type LineLayerTarget struct {
+	Fbo         uint32
+	Texture     uint32
+	DepthBuffer uint32
+	Width       int32
+	Height      int32
+}
+
+// Returns an empty target (Fbo 0) if the framebuffer is incomplete. Lines are then drawn directly.
+func createLineLayerTarget(width, height int32) LineLayerTarget {
+	t := LineLayerTarget{Width: width, Height: height}
+
+	gl.GenTextures(1, &t.Texture)
+	gl.BindTexture(gl.TEXTURE_2D, t.Texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, width, height, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.GenRenderbuffers(1, &t.DepthBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.DepthBuffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, width, height)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+	gl.GenFramebuffers(1, &t.Fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.Fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.Texture, 0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.DepthBuffer)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		t.delete()
+		return LineLayerTarget{}
+	}
+	return t
+}
+
+func (t *LineLayerTarget) delete() {
+	gl.DeleteFramebuffers(1, &t.Fbo)
+	gl.DeleteTextures(1, &t.Texture)
+	gl.DeleteRenderbuffers(1, &t.DepthBuffer)
+	*t = LineLayerTarget{}
+}
+
+// Draws one layer of lines with draw and blends the result over the scene in fbo.
+// Where lines overlap, the one that covers a pixel the most is written, without blending. On ties the first one.
+func renderLineLayer(fbo uint32, draw func()) {
+	if g_lineLayer.Fbo == 0 {
+		draw()
+		return
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g_lineLayer.Fbo)
+	gl.ClearColor(0, 0, 0, 0)
+	gl.ClearDepth(1)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	draw()
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.UseProgram(g_lineLayerShader)
+	gl.BindVertexArray(g_fullscreenQuadGLBuffer.VertexBuffer)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g_fullscreenQuadGLBuffer.IndexBuffer)
+	// Unit 0 keeps the color texture for the next layers.
+	gl.ActiveTexture(gl.TEXTURE3)
+	gl.BindTexture(gl.TEXTURE_2D, g_lineLayer.Texture)
+	gl.Uniform1i(gl.GetUniformLocation(g_lineLayerShader, gl.Str("layerTexture\x00")), 3)
+	gl.DrawElements(gl.TRIANGLES, g_fullscreenQuadGLBuffer.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
@@ -0,0 +1,46 @@
+#version 330
+
+// Keep these in sync with glView.go!
+#define LINE_JOIN_ROUND  0
+#define LINE_JOIN_MITER  1
+
+in fData
+{
+    vec4 color;
+} g_in;
+
+// Set by edges.geo.
+in vec2 linePosition;
+flat in float lineLength;
+flat in vec2 mitered;
+
+// In pixels.
+uniform float lineWidth;
+uniform int lineJoin;
+
+out vec4 colorOut;
+
+void main() {
+    // Distance to the segment. Ends without a miter reach half the width past the vertex,
+    // so the ends of all lines meeting at a vertex overlap and close the join.
+    // The overlap is not blended twice, see lineLayer.go. Mitered ends stop at the miter, edges.geo cuts the quad there.
+    float before = mitered.x > 0.0 ? 0.0 : -linePosition.x;
+    float after = mitered.y > 0.0 ? 0.0 : linePosition.x - lineLength;
+    float beyond = max(max(before, after), 0.0);
+    float distance = length(vec2(beyond, linePosition.y));
+    if (lineJoin == LINE_JOIN_MITER) {
+        distance = max(beyond, abs(linePosition.y));
+    }
+
+    // About one pixel of soft border.
+    float coverage = clamp(lineWidth / 2.0 + 0.5 - distance, 0.0, 1.0);
+    if (coverage <= 0.0) {
+        discard;
+    }
+    // The line layer keeps the fragment that covers a pixel the most, see lineLayer.go.
+    gl_FragDepth = 1.0 - coverage;
+
+    // Premultiplied, see the blend function in renderDelaunay.
+    float alpha = g_in.color.a * coverage;
+    colorOut = vec4(g_in.color.rgb * alpha, alpha);
+}
@@ -0,0 +1,152 @@
+// metricVoronoi
+package main
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	METRIC_MANHATTAN = iota
+	METRIC_CHEBYSHEV = iota
+	METRIC_MINKOWSKI = iota
+	METRIC_EUCLIDEAN = iota
+)
+
+// Distance of d to the origin. exponent is only used for METRIC_MINKOWSKI and must be >= 1.
+func metricLength(metric int, exponent float64, d sc.Vector) float64 {
+	x := math.Abs(d.X)
+	y := math.Abs(d.Y)
+	switch metric {
+	case METRIC_MANHATTAN:
+		return x + y
+	case METRIC_CHEBYSHEV:
+		return math.Max(x, y)
+	case METRIC_EUCLIDEAN:
+		return math.Hypot(x, y)
+	}
+	return math.Pow(math.Pow(x, exponent)+math.Pow(y, exponent), 1.0/exponent)
+}
+
+// Index (into grid.Sites) of the closest site under the given metric.
+// Works like NearestTwo: every Lp distance is at least the Chebyshev distance, so the same ring bound holds.
+func (g SiteGrid) NearestMetric(p sc.Vector, metric int, exponent float64) int {
+	best := math.MaxFloat64
+	nearest := -1
+
+	cx, cy := g.cellOf(p)
+	maxRing := int(math.Max(float64(g.Width), float64(g.Height)))
+
+	for ring := 0; ring <= maxRing; ring++ {
+		for y := cy - ring; y <= cy+ring; y++ {
+			if y < 0 || y >= g.Height {
+				continue
+			}
+			for x := cx - ring; x <= cx+ring; x++ {
+				if x < 0 || x >= g.Width {
+					continue
+				}
+				if y != cy-ring && y != cy+ring && x != cx-ring && x != cx+ring {
+					continue
+				}
+				i := y*g.Width + x
+				for s := g.Start[i]; s < g.Start[i+1]; s++ {
+					d := metricLength(metric, exponent, sc.Sub(g.Sites[s], p))
+					if d < best {
+						best = d
+						nearest = int(s)
+					}
+				}
+			}
+		}
+		if nearest >= 0 && best <= float64(ring)*g.CellSize {
+			break
+		}
+	}
+
+	return nearest
+}
+
+// Rasterizes the Voronoi diagram under the given metric. Returns the site index (into grid.Sites) for every pixel.
+// Pixel rows go down like in an image, so row 0 is at y = rangeY.
+func CreateMetricVoronoiLabels(grid SiteGrid, metric int, exponent float64, width, height int, rangeX, rangeY float64) []int32 {
+	return CreateMetricVoronoiRegionLabels(grid, metric, exponent, width, height, 0, 0, rangeX, rangeY)
+}
+
+// Like CreateMetricVoronoiLabels, but only for the region starting at (x, y) with the size rangeX*rangeY.
+// Row 0 is at y + rangeY.
+func CreateMetricVoronoiRegionLabels(grid SiteGrid, metric int, exponent float64, width, height int, x, y, rangeX, rangeY float64) []int32 {
+	labels := make([]int32, width*height)
+
+	rows := make(chan int, height)
+	for y := 0; y < height; y++ {
+		rows <- y
+	}
+	close(rows)
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for py := range rows {
+				for px := 0; px < width; px++ {
+					p := sc.Vector{x + (float64(px)+0.5)*rangeX/float64(width), y + rangeY - (float64(py)+0.5)*rangeY/float64(height)}
+					labels[py*width+px] = int32(grid.NearestMetric(p, metric, exponent))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return labels
+}
+
+// Average image color and alpha below every cell of the label raster, averaged in the color space.
+// The raster has to cover the whole image.
+func metricVoronoiColors(labels []int32, siteCount int, img *image.RGBA, space, width, height int) []mgl32.Vec4 {
+	sums := make([]mgl32.Vec3, siteCount)
+	alphas := make([]float32, siteCount)
+	counts := make([]int, siteCount)
+
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			l := labels[py*width+px]
+			if l < 0 {
+				continue
+			}
+			c := sampleImageAlpha(img, (float32(px)+0.5)/float32(width), (float32(py)+0.5)/float32(height))
+			sums[l] = sums[l].Add(toColorSpace(c.Vec3(), space).Mul(c.W()))
+			alphas[l] += c.W()
+			counts[l]++
+		}
+	}
+	colors := make([]mgl32.Vec4, siteCount)
+	for i := range sums {
+		if alphas[i] > 0 {
+			colors[i] = fromColorSpace(sums[i].Mul(1.0/alphas[i]), space).Vec4(alphas[i] / float32(counts[i]))
+		}
+	}
+	return colors
+}
+
+// Paints every pixel of the label raster with the color of its cell.
+func paintMetricVoronoi(labels []int32, colors []mgl32.Vec4, width, height int) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			l := labels[py*width+px]
+			if l < 0 {
+				continue
+			}
+			out.SetRGBA(px, py, premultipliedRGBA(colors[l]))
+		}
+	}
+
+	return out
+}
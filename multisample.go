@@ -0,0 +1,79 @@
+// multisample
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Offscreen framebuffer with a multisample color renderbuffer. Works on OpenGL 3.3, unlike multisample textures
+// with the 4.3 framebuffer functions. Everything is rendered into it and then resolved with a blit.
+// An empty target (Fbo 0) renders directly into the window without anti-aliasing.
+// The depth buffer is only used by the depth tests within a layer, like the stained glass leading.
+type MultisampleTarget struct {
+	Fbo         uint32
+	ColorBuffer uint32
+	DepthBuffer uint32
+	Width       int32
+	Height      int32
+	Samples     int32
+}
+
+// Samples are clamped to what the driver supports. Returns an empty target for less than two samples.
+func createMultisampleTarget(width, height, samples int32) MultisampleTarget {
+	var maxSamples int32
+	gl.GetIntegerv(gl.MAX_SAMPLES, &maxSamples)
+	if samples > maxSamples {
+		samples = maxSamples
+	}
+	if samples < 2 || width <= 0 || height <= 0 {
+		return MultisampleTarget{}
+	}
+
+	t := MultisampleTarget{Width: width, Height: height, Samples: samples}
+
+	gl.GenRenderbuffers(1, &t.ColorBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.ColorBuffer)
+	gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, samples, gl.RGBA8, width, height)
+	gl.GenRenderbuffers(1, &t.DepthBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.DepthBuffer)
+	gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, samples, gl.DEPTH_COMPONENT24, width, height)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+	gl.GenFramebuffers(1, &t.Fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.Fbo)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, t.ColorBuffer)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.DepthBuffer)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		fmt.Printf("Multisample framebuffer is incomplete (0x%x). No anti-aliasing.\n", status)
+		t.delete()
+		return MultisampleTarget{}
+	}
+	return t
+}
+
+func (t *MultisampleTarget) delete() {
+	// OpenGL silently ignores 0.
+	gl.DeleteFramebuffers(1, &t.Fbo)
+	gl.DeleteRenderbuffers(1, &t.ColorBuffer)
+	gl.DeleteRenderbuffers(1, &t.DepthBuffer)
+	*t = MultisampleTarget{}
+}
+
+// Averages the samples into the color buffer of drawFbo (0 is the back buffer of the window).
+func (t MultisampleTarget) resolve(drawFbo uint32) {
+	if t.Fbo == 0 {
+		return
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, t.Fbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, drawFbo)
+	if drawFbo == 0 {
+		gl.DrawBuffer(gl.BACK)
+	}
+	gl.BlitFramebuffer(0, 0, t.Width, t.Height, 0, 0, t.Width, t.Height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
@@ -0,0 +1,40 @@
+// palette
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const DEFAULT_PALETTE = "#264653, #2a9d8f, #e9c46a, #f4a261, #e76f51"
+
+// Parses a list of hex colors like "#ff8800, #08f" (separated by commas or spaces) into RGBA colors.
+func ParsePalette(s string) ([]mgl32.Vec4, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n'
+	})
+
+	palette := make([]mgl32.Vec4, 0, len(fields))
+	for _, f := range fields {
+		hex := strings.TrimPrefix(f, "#")
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return nil, fmt.Errorf("invalid color %q", f)
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color %q: %v", f, err)
+		}
+		palette = append(palette, mgl32.Vec4{float32(v>>16&0xff) / 255, float32(v>>8&0xff) / 255, float32(v&0xff) / 255, 1})
+	}
+
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("palette is empty")
+	}
+	return palette, nil
+}
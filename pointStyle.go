@@ -0,0 +1,122 @@
+// pointStyle
+package main
+
+import (
+	"image"
+	"math"
+	"unsafe"
+
+	geo "github.com/MauriceGit/mtGeometry"
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Keep these in sync with points.frag!
+const (
+	POINT_SHAPE_CIRCLE  = iota
+	POINT_SHAPE_SQUARE  = iota
+	POINT_SHAPE_DIAMOND = iota
+	POINT_SHAPE_RING    = iota
+	POINT_SHAPE_HEXAGON = iota
+)
+
+// How the size of every single point is scaled.
+const (
+	// All points have the same size.
+	POINT_SIZE_FIXED = iota
+	// Dark image areas get large points, like a halftone print. The area of a point is proportional to the darkness.
+	POINT_SIZE_DARKNESS = iota
+	// Points of large Voronoi cells are large.
+	POINT_SIZE_CELL_AREA = iota
+)
+
+// Points of cells much larger than the mean would cover their neighbors.
+const g_maxPointSizeFactor = 3.0
+
+// Same layout as geo.Mesh, but with a size factor per point (attribute location 3).
+type PointMesh struct {
+	Pos    mgl32.Vec3
+	Normal mgl32.Vec3
+	UV     mgl32.Vec2
+	Size   float32
+}
+
+func generatePointGeometryArrayAttributes(mesh *[]PointMesh, vertexCount int) geo.ArrayGeometry {
+	geometry := geo.ArrayGeometry{}
+
+	var m PointMesh
+	stride := int32(unsafe.Sizeof(m))
+	var v mgl32.Vec3
+	vStride := int(unsafe.Sizeof(v))
+	var uv mgl32.Vec2
+	uvStride := int(unsafe.Sizeof(uv))
+
+	gl.GenBuffers(1, &geometry.ArrayBuffer)
+	gl.BindBuffer(gl.ARRAY_BUFFER, geometry.ArrayBuffer)
+	if vertexCount > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, int(stride)*vertexCount, gl.Ptr(*mesh), gl.STATIC_DRAW)
+	}
+
+	gl.GenVertexArrays(1, &geometry.VertexBuffer)
+	gl.BindVertexArray(geometry.VertexBuffer)
+
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride, gl.PtrOffset(0))
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(1, 3, gl.FLOAT, true, stride, gl.PtrOffset(vStride))
+	gl.EnableVertexAttribArray(2)
+	gl.VertexAttribPointer(2, 2, gl.FLOAT, false, stride, gl.PtrOffset(2*vStride))
+	gl.EnableVertexAttribArray(3)
+	gl.VertexAttribPointer(3, 1, gl.FLOAT, false, stride, gl.PtrOffset(2*vStride+uvStride))
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+
+	geometry.VertexCount = int32(vertexCount)
+
+	return geometry
+}
+
+// Size of every point relative to the point size of the layer.
+// cells are only needed for POINT_SIZE_CELL_AREA, points without a cell keep their full size.
+func pointSizeFactors(points []sc.Vector, cells []VoronoiCell, mode int, img *image.RGBA, space int, expectedRadius, rangeX, rangeY float64) []float32 {
+	factors := make([]float32, len(points))
+	for i := range factors {
+		factors[i] = 1
+	}
+
+	switch mode {
+	case POINT_SIZE_DARKNESS:
+		r := mgl32.Vec2{float32(expectedRadius / rangeX), float32(expectedRadius / rangeY)}
+		for i, p := range points {
+			uv := positionUV(p, rangeX, rangeY)
+			c := sampleImageRandom(img, r, mgl32.Vec2{uv.X(), 1 - uv.Y()}, space)
+			// Transparent areas are not dark, they are empty.
+			darkness := (1 - float64(c.Vec3().Dot(mgl32.Vec3{0.2126, 0.7152, 0.0722}))) * float64(c.W())
+			factors[i] = float32(math.Sqrt(math.Max(0, darkness)))
+		}
+	case POINT_SIZE_CELL_AREA:
+		areas := make(map[sc.Vector]float64, len(cells))
+		meanArea := 0.0
+		for _, c := range cells {
+			poly := clipPolygonToRect(c.Polygon, 0, 0, rangeX, rangeY)
+			if len(poly) < 3 {
+				continue
+			}
+			areas[c.Site] = math.Abs(signedPolygonArea(poly))
+			meanArea += areas[c.Site]
+		}
+		if len(areas) == 0 || meanArea <= 0 {
+			break
+		}
+		meanArea /= float64(len(areas))
+
+		for i, p := range points {
+			if area, ok := areas[p]; ok {
+				// The diameter grows with the cell diameter.
+				factors[i] = float32(math.Min(g_maxPointSizeFactor, math.Sqrt(area/meanArea)))
+			}
+		}
+	}
+	return factors
+}
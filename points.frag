@@ -1,26 +1,61 @@
 #version 330
 
-uniform vec3 color;
+// Keep these in sync with pointStyle.go!
+#define POINT_SHAPE_CIRCLE   0
+#define POINT_SHAPE_SQUARE   1
+#define POINT_SHAPE_DIAMOND  2
+#define POINT_SHAPE_RING     3
+#define POINT_SHAPE_HEXAGON  4
+
+// Thickness of the ring relative to its diameter.
+#define RING_WIDTH           0.15
+
+uniform vec4 color;
 uniform sampler2D imageTexture;
 uniform bool useExternalColor;
+uniform int pointShape;
 
 in vec2 vUV;
+flat in float shapeSize;
+in vec2 pointCoord;
 out vec4 colorOut;
 
-void main() {
+// Distance in pixels to the border of the shape, negative inside.
+float shapeDistance(vec2 p, float radius) {
+    vec2 q = abs(p);
+    switch (pointShape) {
+        case POINT_SHAPE_SQUARE:
+            return max(q.x, q.y) - radius;
+        case POINT_SHAPE_DIAMOND:
+            return (q.x + q.y - radius) * 0.70710678;
+        case POINT_SHAPE_RING: {
+            float width = RING_WIDTH * radius * 2.0;
+            return abs(length(p) - radius + width/2.0) - width/2.0;
+        }
+        case POINT_SHAPE_HEXAGON:
+            // Corners left and right, flat at the top and bottom.
+            return max(q.y, dot(q, vec2(0.86602540, 0.5))) - radius * 0.86602540;
+    }
+    return length(p) - radius;
+}
 
+void main() {
+    if (shapeSize <= 0.0) {
+        discard;
+    }
 
-    vec2 coord = gl_PointCoord - vec2(0.5);  //from [0,1] to [-0.5,0.5]
+    // From [0,1] to pixels around the center.
+    vec2 p = (pointCoord - vec2(0.5)) * (shapeSize + 1.0);
 
-    if(length(coord) > 0.5) {
+    float coverage = clamp(0.5 - shapeDistance(p, shapeSize / 2.0), 0.0, 1.0);
+    if (coverage <= 0.0) {
         discard;
     }
 
+    // Premultiplied, like the texture.
     if (useExternalColor) {
-        colorOut = vec4(color,1);
+        colorOut = vec4(color.rgb * color.a, color.a) * coverage;
     } else {
-        colorOut = texture(imageTexture, vUV);
+        colorOut = texture(imageTexture, vUV) * coverage;
     }
-
-
 }
@@ -3,13 +3,24 @@
 layout (location = 0) in vec3 vertPos;
 layout (location = 1) in vec3 vertNormal;
 layout (location = 2) in vec2 vertUV;
+// Relative to pointSize, see pointSizeFactors in pointStyle.go.
+layout (location = 3) in float vertSize;
 
 uniform mat4 viewProjectionMat;
 uniform mat4 modelMat;
 
-out vec2 vUV;
+// Diameter in pixels.
+uniform float pointSize;
+
+out vData
+{
+    vec2 uv;
+    // Diameter of the shape in pixels.
+    float shapeSize;
+} v_out;
 
 void main() {
     gl_Position = viewProjectionMat * modelMat * vec4(vertPos,1);
-    vUV = vec2(vertUV.x, 1.0-vertUV.y);
+    v_out.uv = vec2(vertUV.x, 1.0-vertUV.y);
+    v_out.shapeSize = pointSize * vertSize;
 }
@@ -0,0 +1,217 @@
+// proximityGraphs
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+// All of these graphs are subgraphs of the Delaunay triangulation (the k-NN graph with its neighbors),
+// so they are calculated on the CellGraph instead of all point pairs.
+
+type indexEdge struct {
+	a, b int
+}
+
+func (g CellGraph) edges() []indexEdge {
+	edges := []indexEdge{}
+	for a, neighbors := range g {
+		for _, b := range neighbors {
+			if a < b {
+				edges = append(edges, indexEdge{a, b})
+			}
+		}
+	}
+	return edges
+}
+
+func toSimpleEdges(points []sc.Vector, edges []indexEdge) []sc.SimpleEdge {
+	simple := make([]sc.SimpleEdge, len(edges))
+	for i, e := range edges {
+		simple[i] = sc.SimpleEdge{points[e.a], points[e.b]}
+	}
+	return simple
+}
+
+func delaunayPoints(d sc.Delaunay) []sc.Vector {
+	points := make([]sc.Vector, len(d.Vertices))
+	for i, v := range d.Vertices {
+		points[i] = v.Pos
+	}
+	return points
+}
+
+func findRoot(parent []int, v int) int {
+	for parent[v] != v {
+		parent[v] = parent[parent[v]]
+		v = parent[v]
+	}
+	return v
+}
+
+// Euclidean minimum spanning tree with Kruskal. The EMST is always part of the Delaunay triangulation.
+func CreateMinimumSpanningTree(points []sc.Vector, g CellGraph) []sc.SimpleEdge {
+	edges := g.edges()
+	sort.Slice(edges, func(i, j int) bool {
+		return sc.LengthSquared(sc.Sub(points[edges[i].a], points[edges[i].b])) < sc.LengthSquared(sc.Sub(points[edges[j].a], points[edges[j].b]))
+	})
+
+	parent := make([]int, len(points))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	tree := []indexEdge{}
+	for _, e := range edges {
+		ra := findRoot(parent, e.a)
+		rb := findRoot(parent, e.b)
+		if ra != rb {
+			parent[ra] = rb
+			tree = append(tree, e)
+		}
+	}
+
+	return toSimpleEdges(points, tree)
+}
+
+// An edge is in the Gabriel graph, if the circle with the edge as diameter is empty.
+// For a Delaunay edge, only the opposite corners of the two adjacent triangles can be inside,
+// and those are exactly the common neighbors of both end points.
+func CreateGabrielGraph(points []sc.Vector, g CellGraph) []sc.SimpleEdge {
+	gabriel := []indexEdge{}
+
+	for _, e := range g.edges() {
+		p := points[e.a]
+		q := points[e.b]
+		isGabriel := true
+		for _, r := range commonNeighbors(g, e.a, e.b) {
+			// r is inside the circle with diameter pq exactly if the angle at r is obtuse.
+			if sc.Dot(sc.Sub(p, points[r]), sc.Sub(q, points[r])) < 0 {
+				isGabriel = false
+				break
+			}
+		}
+		if isGabriel {
+			gabriel = append(gabriel, e)
+		}
+	}
+
+	return toSimpleEdges(points, gabriel)
+}
+
+func commonNeighbors(g CellGraph, a, b int) []int {
+	common := []int{}
+	for _, na := range g[a] {
+		for _, nb := range g[b] {
+			if na == nb {
+				common = append(common, na)
+			}
+		}
+	}
+	return common
+}
+
+// An edge pq is in the relative neighborhood graph, if no other point r is closer to both p and q
+// than they are to each other (the lune between p and q is empty).
+func CreateRelativeNeighborhoodGraph(points []sc.Vector, g CellGraph) []sc.SimpleEdge {
+	rng := []indexEdge{}
+
+	for _, e := range g.edges() {
+		p := points[e.a]
+		q := points[e.b]
+		dist := sc.LengthSquared(sc.Sub(p, q))
+
+		isRNG := true
+		// Every point in the lune is closer to p than q is.
+		for _, r := range nearestNeighborsWithin(points, g, e.a, dist) {
+			if r != e.b && sc.LengthSquared(sc.Sub(q, points[r])) < dist {
+				isRNG = false
+				break
+			}
+		}
+		if isRNG {
+			rng = append(rng, e)
+		}
+	}
+
+	return toSimpleEdges(points, rng)
+}
+
+// Connects every point with its k nearest neighbors. Edges that exist in both directions are only added once.
+func CreateKNearestNeighborGraph(points []sc.Vector, g CellGraph, k int) []sc.SimpleEdge {
+	exists := map[indexEdge]bool{}
+	knn := []indexEdge{}
+
+	for p := range points {
+		for _, n := range nearestNeighbors(points, g, p, k) {
+			e := indexEdge{p, n}
+			if n < p {
+				e = indexEdge{n, p}
+			}
+			if !exists[e] {
+				exists[e] = true
+				knn = append(knn, e)
+			}
+		}
+	}
+
+	return toSimpleEdges(points, knn)
+}
+
+type neighborCandidate struct {
+	index int
+	dist  float64
+}
+type neighborQueue []neighborCandidate
+
+func (q neighborQueue) Len() int            { return len(q) }
+func (q neighborQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q neighborQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *neighborQueue) Push(x interface{}) { *q = append(*q, x.(neighborCandidate)) }
+func (q *neighborQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// Best first search over the Delaunay graph, starting at p. Returns the other points in order of increasing
+// distance to p. Stops after count points or when the next point is not closer than maxDistSquared.
+// This is exact, because every i-th nearest neighbor is a Delaunay neighbor of p or of a closer neighbor.
+func searchNeighbors(points []sc.Vector, g CellGraph, p int, count int, maxDistSquared float64) []int {
+	found := []int{}
+	visited := map[int]bool{p: true}
+
+	q := &neighborQueue{}
+	for _, n := range g[p] {
+		visited[n] = true
+		heap.Push(q, neighborCandidate{n, sc.LengthSquared(sc.Sub(points[n], points[p]))})
+	}
+
+	for q.Len() > 0 && len(found) < count {
+		c := heap.Pop(q).(neighborCandidate)
+		if c.dist >= maxDistSquared {
+			break
+		}
+		found = append(found, c.index)
+		for _, n := range g[c.index] {
+			if !visited[n] {
+				visited[n] = true
+				heap.Push(q, neighborCandidate{n, sc.LengthSquared(sc.Sub(points[n], points[p]))})
+			}
+		}
+	}
+
+	return found
+}
+
+func nearestNeighbors(points []sc.Vector, g CellGraph, p int, k int) []int {
+	return searchNeighbors(points, g, p, k, math.MaxFloat64)
+}
+
+func nearestNeighborsWithin(points []sc.Vector, g CellGraph, p int, maxDistSquared float64) []int {
+	return searchNeighbors(points, g, p, len(points), maxDistSquared)
+}
@@ -0,0 +1,57 @@
+// rasterize
+package main
+
+import (
+	"math"
+
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+// Calls fn for every pixel whose center lies inside the convex polygon.
+// The polygon is in window coordinates ([0,rangeX]x[0,rangeY], y up), the raster has width x height pixels
+// and row 0 at the top, like an image. Edges are half open, so neighbouring polygons never share a pixel.
+func rasterizeConvexPolygon(poly []sc.Vector, width, height int, rangeX, rangeY float64, fn func(x, y int)) {
+	if len(poly) < 3 {
+		return
+	}
+
+	scaleX := float64(width) / rangeX
+	scaleY := float64(height) / rangeY
+
+	raster := make([]sc.Vector, len(poly))
+	minY := math.MaxFloat64
+	maxY := -math.MaxFloat64
+	for i, p := range poly {
+		raster[i] = sc.Vector{p.X * scaleX, (rangeY - p.Y) * scaleY}
+		minY = math.Min(minY, raster[i].Y)
+		maxY = math.Max(maxY, raster[i].Y)
+	}
+
+	firstRow := int(math.Max(0, math.Ceil(minY-0.5)))
+	lastRow := int(math.Min(float64(height), math.Ceil(maxY-0.5)))
+
+	for py := firstRow; py < lastRow; py++ {
+		yc := float64(py) + 0.5
+
+		minX := math.MaxFloat64
+		maxX := -math.MaxFloat64
+		for i, a := range raster {
+			b := raster[(i+1)%len(raster)]
+			if (a.Y <= yc) == (b.Y <= yc) {
+				continue
+			}
+			x := a.X + (yc-a.Y)/(b.Y-a.Y)*(b.X-a.X)
+			minX = math.Min(minX, x)
+			maxX = math.Max(maxX, x)
+		}
+		if minX > maxX {
+			continue
+		}
+
+		firstCol := int(math.Max(0, math.Ceil(minX-0.5)))
+		lastCol := int(math.Min(float64(width), math.Ceil(maxX-0.5)))
+		for px := firstCol; px < lastCol; px++ {
+			fn(px, py)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// relief
+package main
+
+import (
+	"image"
+	"math"
+
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Where the pseudo 3D normals of the faces come from.
+const (
+	// Flat faces, no lighting.
+	RELIEF_NONE = iota
+	// The image luminance is the height. One plane per face.
+	RELIEF_LUMINANCE = iota
+	// Every face is tilted in a random direction.
+	RELIEF_RANDOM = iota
+	// Every face is a pyramid with its peak at the site (or the triangle center).
+	RELIEF_PYRAMID = iota
+)
+
+type Relief struct {
+	Mode int
+	// Height of the relief in pixels.
+	Height float64
+	// Expected radius of a face in pixels. Random tilts rise by Height over it.
+	Radius float64
+	Seed   uint32
+}
+
+// Lights the faces in triangles.vert.
+type DirectionalLight struct {
+	// Direction the light comes from in degrees. An azimuth of 0 is from the right, 90 from the top.
+	Azimuth   float32
+	Elevation float32
+	// Part of the color that is always lit.
+	Ambient   float32
+	Specular  float32
+	Shininess float32
+}
+
+// Direction to the light.
+func (l DirectionalLight) Direction() mgl32.Vec3 {
+	azimuth := float64(mgl32.DegToRad(l.Azimuth))
+	elevation := float64(mgl32.DegToRad(l.Elevation))
+	return mgl32.Vec3{
+		float32(math.Cos(azimuth) * math.Cos(elevation)),
+		float32(math.Sin(azimuth) * math.Cos(elevation)),
+		float32(math.Sin(elevation)),
+	}
+}
+
+var flatNormal = mgl32.Vec3{0, 0, 1}
+
+// Normal of the plane z = a*x + b*y + c.
+func slopeNormal(a, b float64) mgl32.Vec3 {
+	return mgl32.Vec3{float32(-a), float32(-b), 1}.Normalize()
+}
+
+// Least squares fit of z = a*x + b*y + c through the points. Degenerate point sets are flat.
+func fitPlaneSlope(points []sc.Vector, heights []float64) (a, b float64) {
+	var cx, cy, cz float64
+	for i, p := range points {
+		cx += p.X
+		cy += p.Y
+		cz += heights[i]
+	}
+	n := float64(len(points))
+	cx, cy, cz = cx/n, cy/n, cz/n
+
+	var xx, xy, yy, xz, yz float64
+	for i, p := range points {
+		dx, dy, dz := p.X-cx, p.Y-cy, heights[i]-cz
+		xx += dx * dx
+		xy += dx * dy
+		yy += dy * dy
+		xz += dx * dz
+		yz += dy * dz
+	}
+	det := xx*yy - xy*xy
+	if math.Abs(det) < 1e-9 {
+		return 0, 0
+	}
+	return (xz*yy - yz*xy) / det, (yz*xx - xz*xy) / det
+}
+
+// Luminance of the image under p. p is in the same space as the vertices, so y points up.
+func luminanceAt(img *image.RGBA, p sc.Vector, rangeX, rangeY float64) float64 {
+	c := sampleImage(img, float32(p.X/rangeX), float32(1-p.Y/rangeY))
+	return float64(c.Dot(mgl32.Vec3{0.2126, 0.7152, 0.0722}))
+}
+
+// Splits a convex polygon into triangles with one normal each.
+// Pyramids are split at center, everything else is a fan with one normal for the whole polygon.
+func reliefFacets(polygon []sc.Vector, center sc.Vector, relief Relief, img *image.RGBA, rangeX, rangeY float64) ([][3]sc.Vector, []mgl32.Vec3) {
+	triangles := make([][3]sc.Vector, 0, len(polygon))
+	normals := make([]mgl32.Vec3, 0, len(polygon))
+
+	if relief.Mode == RELIEF_PYRAMID {
+		peak := mgl32.Vec3{float32(center.X), float32(center.Y), float32(relief.Height)}
+		for i := range polygon {
+			a := polygon[i]
+			b := polygon[(i+1)%len(polygon)]
+			pa := mgl32.Vec3{float32(a.X), float32(a.Y), 0}
+			pb := mgl32.Vec3{float32(b.X), float32(b.Y), 0}
+			normal := pa.Sub(peak).Cross(pb.Sub(peak))
+			if normal.Len() == 0 {
+				continue
+			}
+			normal = normal.Normalize()
+			// Independent of the winding order.
+			if normal.Z() < 0 {
+				normal = normal.Mul(-1)
+			}
+			triangles = append(triangles, [3]sc.Vector{center, a, b})
+			normals = append(normals, normal)
+		}
+		return triangles, normals
+	}
+
+	normal := flatNormal
+	switch relief.Mode {
+	case RELIEF_LUMINANCE:
+		heights := make([]float64, len(polygon))
+		for i, p := range polygon {
+			heights[i] = luminanceAt(img, p, rangeX, rangeY) * relief.Height
+		}
+		normal = slopeNormal(fitPlaneSlope(polygon, heights))
+	case RELIEF_RANDOM:
+		uv := positionUV(center, rangeX, rangeY)
+		h := hashUint(math.Float32bits(uv.X()) ^ hashUint(math.Float32bits(uv.Y())^hashUint(relief.Seed)))
+		tiltX := float64(hashToSigned(hashUint(h)))
+		tiltY := float64(hashToSigned(hashUint(hashUint(h))))
+		if relief.Radius > 0 {
+			normal = slopeNormal(tiltX*relief.Height/relief.Radius, tiltY*relief.Height/relief.Radius)
+		}
+	}
+
+	for i := 1; i < len(polygon)-1; i++ {
+		triangles = append(triangles, [3]sc.Vector{polygon[0], polygon[i], polygon[i+1]})
+		normals = append(normals, normal)
+	}
+	return triangles, normals
+}
@@ -0,0 +1,110 @@
+// shaders
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Code that several shaders need (like the color space conversions) lives in its own .glsl file.
+// A line like #include "colorSpace.glsl" is replaced by that file before compiling.
+// The path is relative to the including shader.
+func readShader(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#include") {
+			continue
+		}
+		include := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "#include")), "\"")
+		source, err := ioutil.ReadFile(filepath.Join(filepath.Dir(name), include))
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", name, err)
+		}
+		lines[i] = string(source)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func compileShader(name string, shaderType uint32) (uint32, error) {
+	source, err := readShader(name)
+	if err != nil {
+		return 0, err
+	}
+
+	shader := gl.CreateShader(shaderType)
+	csources, free := gl.Strs(source)
+	length := int32(len(source))
+	gl.ShaderSource(shader, 1, csources, &length)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+
+		return 0, fmt.Errorf("failed to compile %v: %v", name, log)
+	}
+	return shader, nil
+}
+
+// Like mtgl.NewProgram (without tessellation), but the shaders can #include shared code.
+// geometryName may be empty.
+func newProgram(vertexName, geometryName, fragmentName string) (uint32, error) {
+	program := gl.CreateProgram()
+
+	var shaders []uint32
+	defer func() {
+		for _, s := range shaders {
+			gl.DeleteShader(s)
+		}
+	}()
+
+	stages := []struct {
+		name       string
+		shaderType uint32
+	}{{vertexName, gl.VERTEX_SHADER}, {geometryName, gl.GEOMETRY_SHADER}, {fragmentName, gl.FRAGMENT_SHADER}}
+	for _, stage := range stages {
+		if stage.name == "" {
+			continue
+		}
+		shader, err := compileShader(stage.name, stage.shaderType)
+		if err != nil {
+			gl.DeleteProgram(program)
+			return 0, err
+		}
+		gl.AttachShader(program, shader)
+		shaders = append(shaders, shader)
+	}
+	gl.LinkProgram(program)
+
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
+
+		return 0, fmt.Errorf("failed to link program: %v", log)
+	}
+
+	return program, nil
+}
@@ -2,11 +2,22 @@
 
 in fData
 {
-    vec3 color;
+    vec4 color;
 } g_in;
 
 out vec4 colorOut;
 
+// Set together with triangles.vert.
+uniform bool interpolateInColorSpace;
+uniform bool useExternalColor;
+
+#include "colorSpace.glsl"
+
 void main() {
-    colorOut = vec4(g_in.color, 1);
+    vec3 c = g_in.color.rgb;
+    if (interpolateInColorSpace && !useExternalColor) {
+        c = fromColorSpace(c);
+    }
+    // Premultiplied, see the blend function in renderDelaunay.
+    colorOut = vec4(c * g_in.color.a, g_in.color.a);
 }
@@ -3,6 +3,8 @@
 layout (location = 0) in vec3 vertPos;
 layout (location = 1) in vec3 vertNormal;
 layout (location = 2) in vec2 vertUV;
+// Only set for edges that are colored by their faces.
+layout (location = 3) in vec4 vertColor;
 
 uniform mat4 viewProjectionMat;
 uniform mat4 modelMat;
@@ -12,10 +14,12 @@ uniform mat4 modelMat;
 out vData
 {
     vec2 uv;
+    vec4 color;
 } v_out;
 
 void main() {
     gl_Position = viewProjectionMat * modelMat * vec4(vertPos,1);
 
     v_out.uv = vec2(vertUV.x, 1.0-vertUV.y);
+    v_out.color = vertColor;
 }
@@ -0,0 +1,45 @@
+// stainedGlass
+package main
+
+import (
+	"math"
+
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+// Triangles of the lead came around every polygon: one quad per edge and a disc at every corner for round joins.
+// Core profile lines can not be wider than one pixel, so the leading is real geometry.
+// Edges shared by two cells and the corner discs overlap. They are drawn with a depth test, so every pixel is only
+// covered once and a translucent leading color stays even.
+func createLeadingTriangles(polygons [][]sc.Vector, width float64, joinSegments int) [][3]sc.Vector {
+	triangles := make([][3]sc.Vector, 0)
+	if width <= 0 {
+		return triangles
+	}
+	halfWidth := width / 2
+
+	for _, polygon := range polygons {
+		for i, a := range polygon {
+			b := polygon[(i+1)%len(polygon)]
+
+			direction := sc.Sub(b, a)
+			if sc.Length(direction) > 0 {
+				offset := sc.Mult(sc.Normalize(sc.Perpendicular(direction)), halfWidth)
+				a1, a2 := sc.Add(a, offset), sc.Sub(a, offset)
+				b1, b2 := sc.Add(b, offset), sc.Sub(b, offset)
+				triangles = append(triangles, [3]sc.Vector{a1, b1, b2}, [3]sc.Vector{a1, b2, a2})
+			}
+
+			for s := 0; s < joinSegments; s++ {
+				angle1 := 2 * math.Pi * float64(s) / float64(joinSegments)
+				angle2 := 2 * math.Pi * float64(s+1) / float64(joinSegments)
+				triangles = append(triangles, [3]sc.Vector{
+					a,
+					sc.Add(a, sc.Vector{halfWidth * math.Cos(angle1), halfWidth * math.Sin(angle1)}),
+					sc.Add(a, sc.Vector{halfWidth * math.Cos(angle2), halfWidth * math.Sin(angle2)}),
+				})
+			}
+		}
+	}
+	return triangles
+}
@@ -0,0 +1,300 @@
+// statistics
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Number of bins of every histogram in the report.
+const g_reportHistogramBins = 20
+
+// Statistics of one Voronoi cell. The cell is clipped to the image before anything is measured.
+type CellStats struct {
+	Index          int
+	Site           sc.Vector
+	Area           float64
+	Perimeter      float64
+	Neighbors      int
+	Centroid       sc.Vector
+	CentroidOffset float64
+	MeanColor      [3]float64
+	ColorVariance  float64
+}
+
+// Quality of one Delaunay triangle. The aspect ratio is circumradius / (2 * inradius), so 1 is equilateral.
+type TriangleStats struct {
+	Index       int
+	Corners     [3]sc.Vector
+	Area        float64
+	MinAngle    float64
+	AspectRatio float64
+}
+
+type Histogram struct {
+	Min, Max float64
+	Counts   []int
+}
+
+type StatisticsReport struct {
+	Cells      []CellStats
+	Triangles  []TriangleStats
+	Histograms map[string]Histogram
+}
+
+func polygonCentroid(poly []sc.Vector) sc.Vector {
+	area := signedPolygonArea(poly)
+	if math.Abs(area) <= sc.EPS {
+		c := sc.Vector{}
+		for _, p := range poly {
+			c = sc.Add(c, p)
+		}
+		return sc.Mult(c, 1.0/float64(len(poly)))
+	}
+	cx, cy := 0.0, 0.0
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		cross := a.X*b.Y - b.X*a.Y
+		cx += (a.X + b.X) * cross
+		cy += (a.Y + b.Y) * cross
+	}
+	return sc.Vector{cx / (3.0 * area), cy / (3.0 * area)}
+}
+
+func polygonPerimeter(poly []sc.Vector) float64 {
+	perimeter := 0.0
+	for i, a := range poly {
+		perimeter += sc.Length(sc.Sub(poly[(i+1)%len(poly)], a))
+	}
+	return perimeter
+}
+
+// Smallest interior angle in degrees.
+func minTriangleAngle(t [3]sc.Vector) float64 {
+	minAngle := 180.0
+	for i := 0; i < 3; i++ {
+		a := sc.Sub(t[(i+1)%3], t[i])
+		b := sc.Sub(t[(i+2)%3], t[i])
+		la := sc.Length(a)
+		lb := sc.Length(b)
+		if la <= sc.EPS || lb <= sc.EPS {
+			return 0
+		}
+		angle := math.Acos(math.Max(-1, math.Min(1, sc.Dot(a, b)/(la*lb)))) * 180.0 / math.Pi
+		minAngle = math.Min(minAngle, angle)
+	}
+	return minAngle
+}
+
+func triangleAspectRatio(t [3]sc.Vector) float64 {
+	area := math.Abs(sc.SideOfLine(t[0], t[1], t[2])) / 2.0
+	if area <= sc.EPS {
+		return math.Inf(1)
+	}
+	inradius := area / (polygonPerimeter(t[:]) / 2.0)
+	return circumradius(t[0], t[1], t[2]) / (2.0 * inradius)
+}
+
+func createHistogram(values []float64, bins int) Histogram {
+	h := Histogram{Min: math.MaxFloat64, Max: -math.MaxFloat64, Counts: make([]int, bins)}
+	for _, v := range values {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			continue
+		}
+		h.Min = math.Min(h.Min, v)
+		h.Max = math.Max(h.Max, v)
+	}
+	if h.Min > h.Max {
+		h.Min, h.Max = 0, 0
+		return h
+	}
+	for _, v := range values {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			continue
+		}
+		bin := bins - 1
+		if h.Max > h.Min {
+			bin = int(math.Min(float64(bins-1), (v-h.Min)/(h.Max-h.Min)*float64(bins)))
+		}
+		h.Counts[bin]++
+	}
+	return h
+}
+
+// Measures all cells and triangles. Colors are averaged (in space) over every pixel of the full resolution image
+// that lies inside the (clipped) cell. The color variance is always measured on the sRGB values.
+func CreateStatisticsReport(d sc.Delaunay, v sc.Voronoi, img *image.RGBA, space int, rangeX, rangeY float64) StatisticsReport {
+	report := StatisticsReport{Histograms: make(map[string]Histogram)}
+
+	graph := CreateCellAdjacencyGraph(d)
+	cells := extractVoronoiCells(v, rangeX, rangeY)
+
+	for i, c := range cells {
+		poly := clipPolygonToRect(c.Polygon, 0, 0, rangeX, rangeY)
+
+		stats := CellStats{Index: i, Site: c.Site}
+		if i < len(graph) {
+			stats.Neighbors = len(graph[i])
+		}
+		if len(poly) >= 3 {
+			stats.Area = math.Abs(signedPolygonArea(poly)) / 2.0
+			stats.Perimeter = polygonPerimeter(poly)
+			stats.Centroid = polygonCentroid(poly)
+			stats.CentroidOffset = sc.Length(sc.Sub(stats.Centroid, c.Site))
+
+			var colors, converted cellColorStats
+			rasterizeConvexPolygon(poly, img.Rect.Dx(), img.Rect.Dy(), rangeX, rangeY, func(x, y int) {
+				c := imagePixel(img, x, y)
+				colors.add(c)
+				converted.add(toColorSpace(c, space))
+			})
+			if colors.count > 0 {
+				mean := converted.mean()
+				meanColor := fromColorSpace(mgl32.Vec3{float32(mean[0]), float32(mean[1]), float32(mean[2])}, space)
+				stats.MeanColor = [3]float64{float64(meanColor[0]), float64(meanColor[1]), float64(meanColor[2])}
+			}
+			stats.ColorVariance = colors.variance()
+		}
+		report.Cells = append(report.Cells, stats)
+	}
+
+	for i, t := range delaunayTriangles(d) {
+		report.Triangles = append(report.Triangles, TriangleStats{
+			Index:       i,
+			Corners:     t,
+			Area:        math.Abs(sc.SideOfLine(t[0], t[1], t[2])) / 2.0,
+			MinAngle:    minTriangleAngle(t),
+			AspectRatio: triangleAspectRatio(t),
+		})
+	}
+
+	cellValues := func(f func(CellStats) float64) []float64 {
+		values := make([]float64, len(report.Cells))
+		for i, c := range report.Cells {
+			values[i] = f(c)
+		}
+		return values
+	}
+	triangleValues := func(f func(TriangleStats) float64) []float64 {
+		values := make([]float64, len(report.Triangles))
+		for i, t := range report.Triangles {
+			values[i] = f(t)
+		}
+		return values
+	}
+
+	report.Histograms["cellArea"] = createHistogram(cellValues(func(c CellStats) float64 { return c.Area }), g_reportHistogramBins)
+	report.Histograms["cellPerimeter"] = createHistogram(cellValues(func(c CellStats) float64 { return c.Perimeter }), g_reportHistogramBins)
+	report.Histograms["cellNeighbors"] = createHistogram(cellValues(func(c CellStats) float64 { return float64(c.Neighbors) }), g_reportHistogramBins)
+	report.Histograms["cellCentroidOffset"] = createHistogram(cellValues(func(c CellStats) float64 { return c.CentroidOffset }), g_reportHistogramBins)
+	report.Histograms["cellColorVariance"] = createHistogram(cellValues(func(c CellStats) float64 { return c.ColorVariance }), g_reportHistogramBins)
+	report.Histograms["triangleMinAngle"] = createHistogram(triangleValues(func(t TriangleStats) float64 { return t.MinAngle }), g_reportHistogramBins)
+	report.Histograms["triangleAspectRatio"] = createHistogram(triangleValues(func(t TriangleStats) float64 { return t.AspectRatio }), g_reportHistogramBins)
+
+	return report
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func writeCSV(path string, records [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.WriteAll(records)
+	return w.Error()
+}
+
+// Writes three files: the cells to path, the triangles and the histograms next to it.
+func (r StatisticsReport) WriteCSV(path string) error {
+	base := strings.TrimSuffix(path, ".csv")
+
+	cells := [][]string{{"index", "siteX", "siteY", "area", "perimeter", "neighbors", "centroidX", "centroidY", "centroidOffset", "meanR", "meanG", "meanB", "colorVariance"}}
+	for _, c := range r.Cells {
+		cells = append(cells, []string{
+			strconv.Itoa(c.Index), formatFloat(c.Site.X), formatFloat(c.Site.Y), formatFloat(c.Area), formatFloat(c.Perimeter), strconv.Itoa(c.Neighbors),
+			formatFloat(c.Centroid.X), formatFloat(c.Centroid.Y), formatFloat(c.CentroidOffset),
+			formatFloat(c.MeanColor[0]), formatFloat(c.MeanColor[1]), formatFloat(c.MeanColor[2]), formatFloat(c.ColorVariance),
+		})
+	}
+
+	triangles := [][]string{{"index", "x1", "y1", "x2", "y2", "x3", "y3", "area", "minAngle", "aspectRatio"}}
+	for _, t := range r.Triangles {
+		triangles = append(triangles, []string{
+			strconv.Itoa(t.Index),
+			formatFloat(t.Corners[0].X), formatFloat(t.Corners[0].Y), formatFloat(t.Corners[1].X), formatFloat(t.Corners[1].Y), formatFloat(t.Corners[2].X), formatFloat(t.Corners[2].Y),
+			formatFloat(t.Area), formatFloat(t.MinAngle), formatFloat(t.AspectRatio),
+		})
+	}
+
+	histograms := [][]string{{"name", "bin", "from", "to", "count"}}
+	for _, name := range []string{"cellArea", "cellPerimeter", "cellNeighbors", "cellCentroidOffset", "cellColorVariance", "triangleMinAngle", "triangleAspectRatio"} {
+		h := r.Histograms[name]
+		width := (h.Max - h.Min) / float64(len(h.Counts))
+		for i, count := range h.Counts {
+			histograms = append(histograms, []string{name, strconv.Itoa(i), formatFloat(h.Min + float64(i)*width), formatFloat(h.Min + float64(i+1)*width), strconv.Itoa(count)})
+		}
+	}
+
+	if err := writeCSV(base+".csv", cells); err != nil {
+		return err
+	}
+	if err := writeCSV(base+"_triangles.csv", triangles); err != nil {
+		return err
+	}
+	return writeCSV(base+"_histograms.csv", histograms)
+}
+
+// JSON can not represent infinity, so degenerated triangles get an aspect ratio of -1.
+func (r StatisticsReport) WriteJSON(path string) error {
+	triangles := make([]TriangleStats, len(r.Triangles))
+	copy(triangles, r.Triangles)
+	for i := range triangles {
+		if math.IsInf(triangles[i].AspectRatio, 0) {
+			triangles[i].AspectRatio = -1
+		}
+	}
+	exported := r
+	exported.Triangles = triangles
+
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
+
+// Writes JSON for a .json path and CSV otherwise.
+func (r StatisticsReport) Write(path string) {
+	var err error
+	if strings.HasSuffix(path, ".json") {
+		err = r.WriteJSON(path)
+	} else {
+		err = r.WriteCSV(path)
+	}
+	if err != nil {
+		fmt.Printf("error when writing the statistics: %v\n", err)
+	}
+}
@@ -0,0 +1,27 @@
+// Needs colorSpace.glsl.
+
+uniform sampler2D imageTexture;
+
+// The texture is premultiplied with alpha. Returns the color in the color space, weighted by alpha, so
+// transparent samples do not darken the average. Alpha stays in .a.
+vec4 weightedSample(vec4 tex) {
+    if (tex.a <= 0.0) {
+        return vec4(0);
+    }
+    return vec4(toColorSpace(tex.rgb/tex.a) * tex.a, tex.a);
+}
+
+// Samples randomly within the radius r around pos and returns the average sample color over all 6 samples.
+vec4 sampleTextureRandom(vec2 r, vec2 pos) {
+    vec4 color = weightedSample(texture(imageTexture, pos + r*(2*vec2(0.36123,0.83771)-1.0)));
+    color += weightedSample(texture(imageTexture, pos +     r*(2*vec2(0.47154,0.44896)-1.0)));
+    color += weightedSample(texture(imageTexture, pos +     r*(2*vec2(0.93110,0.64977)-1.0)));
+    color += weightedSample(texture(imageTexture, pos +     r*(2*vec2(0.15231,0.46326)-1.0)));
+    color += weightedSample(texture(imageTexture, pos +     r*(2*vec2(0.83720,0.11699)-1.0)));
+    color += weightedSample(texture(imageTexture, pos +     r*(2*vec2(0.30478,0.06818)-1.0)));
+
+    if (color.a <= 0.0) {
+        return vec4(0);
+    }
+    return vec4(fromColorSpace(color.rgb/color.a), color.a/6);
+}
@@ -0,0 +1,257 @@
+// triangleOptimizer
+package main
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"time"
+
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+// The optimizer compares against a downscaled image with at most this many pixels per side.
+const g_optimizerRasterSize = 160
+
+// Probability that an optimizer step moves a vertex into the worst triangle instead of just jittering it.
+const g_optimizerRelocateProbability = 0.2
+
+// All triangles of a Delaunay triangulation as corner positions.
+func delaunayTriangles(d sc.Delaunay) [][3]sc.Vector {
+	triangles := make([][3]sc.Vector, len(d.Faces))
+	for i, f := range d.Faces {
+		e1 := f.EEdge
+		e2 := d.Edges[e1].ENext
+		e3 := d.Edges[e2].ENext
+		triangles[i] = [3]sc.Vector{d.Vertices[d.Edges[e1].VOrigin].Pos, d.Vertices[d.Edges[e2].VOrigin].Pos, d.Vertices[d.Edges[e3].VOrigin].Pos}
+	}
+	return triangles
+}
+
+// Image as plain float colors, box filtered down to the given size.
+type colorRaster struct {
+	width, height int
+	pixels        [][3]float64
+}
+
+func createColorRaster(img *image.RGBA, width, height int) colorRaster {
+	r := colorRaster{width, height, make([][3]float64, width*height)}
+	counts := make([]int, width*height)
+
+	w := img.Rect.Dx()
+	h := img.Rect.Dy()
+	for y := 0; y < h; y++ {
+		ry := y * height / h
+		for x := 0; x < w; x++ {
+			rx := x * width / w
+			i := img.PixOffset(x+img.Rect.Min.X, y+img.Rect.Min.Y)
+			p := &r.pixels[ry*width+rx]
+			p[0] += float64(img.Pix[i]) / 255
+			p[1] += float64(img.Pix[i+1]) / 255
+			p[2] += float64(img.Pix[i+2]) / 255
+			counts[ry*width+rx]++
+		}
+	}
+	for i := range r.pixels {
+		if counts[i] > 0 {
+			for c := 0; c < 3; c++ {
+				r.pixels[i][c] /= float64(counts[i])
+			}
+		}
+	}
+	return r
+}
+
+// Squared color error of a triangle when it is filled with its mean color.
+func triangleError(t [3]sc.Vector, raster colorRaster, rangeX, rangeY float64) float64 {
+	var sum, sumSquared [3]float64
+	count := 0
+	rasterizeConvexPolygon(t[:], raster.width, raster.height, rangeX, rangeY, func(x, y int) {
+		p := raster.pixels[y*raster.width+x]
+		for c := 0; c < 3; c++ {
+			sum[c] += p[c]
+			sumSquared[c] += p[c] * p[c]
+		}
+		count++
+	})
+	e := 0.0
+	if count > 0 {
+		for c := 0; c < 3; c++ {
+			e += sumSquared[c] - sum[c]*sum[c]/float64(count)
+		}
+	}
+	return e
+}
+
+// The same triangle always gets the same key, no matter which corner the triangulation starts with.
+func triangleKey(t [3]sc.Vector) [3]sc.Vector {
+	first := 0
+	for i := 1; i < 3; i++ {
+		if t[i].X < t[first].X || (t[i].X == t[first].X && t[i].Y < t[first].Y) {
+			first = i
+		}
+	}
+	return [3]sc.Vector{t[first], t[(first+1)%3], t[(first+2)%3]}
+}
+
+// Errors of all triangles. Triangles that are in known already are not rasterized again.
+// A single vertex move only changes the triangles around it, so that is most of them.
+func triangleErrors(triangles [][3]sc.Vector, known map[[3]sc.Vector]float64, raster colorRaster, rangeX, rangeY float64) []float64 {
+	errors := make([]float64, len(triangles))
+	for i, t := range triangles {
+		if e, ok := known[triangleKey(t)]; ok {
+			errors[i] = e
+			continue
+		}
+		errors[i] = triangleError(t, raster, rangeX, rangeY)
+	}
+	return errors
+}
+
+func triangleErrorMap(triangles [][3]sc.Vector, errors []float64) map[[3]sc.Vector]float64 {
+	known := make(map[[3]sc.Vector]float64, len(triangles))
+	for i, t := range triangles {
+		known[triangleKey(t)] = errors[i]
+	}
+	return known
+}
+
+func sumOf(values []float64) float64 {
+	s := 0.0
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+// Everything the optimized points depend on. A finished optimization is reused as long as this stays the same.
+type optimizerInput struct {
+	points         []sc.Vector
+	img            *image.RGBA
+	iterations     int
+	budget         time.Duration
+	rangeX, rangeY float64
+	seed           int64
+}
+
+func (a optimizerInput) equals(b optimizerInput) bool {
+	if a.img != b.img || a.iterations != b.iterations || a.budget != b.budget || a.rangeX != b.rangeX || a.rangeY != b.rangeY || a.seed != b.seed {
+		return false
+	}
+	if len(a.points) != len(b.points) {
+		return false
+	}
+	for i := range a.points {
+		if a.points[i] != b.points[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// An optimization running in the background. Closing cancel stops it.
+type optimizerJob struct {
+	input  optimizerInput
+	cancel chan struct{}
+}
+
+// Moves the points with simulated annealing so the flat shaded Delaunay triangulation matches the image as well as possible.
+// There are two kinds of steps: A vertex is jittered, or it is removed and inserted again inside the triangle with the
+// largest error. The point count stays the same, so the result still has as many points as the distribution asked for.
+// Edges are not flipped on their own. The connectivity is always the Delaunay triangulation of the points,
+// which the Voronoi cells and everything else are built from. A move that changes it flips the edges around the vertex.
+// The four image corners are added (and kept fixed) while evaluating, so the triangulation always covers the whole image.
+// They are not part of the result.
+//
+// Stops after iterations steps or when the time budget is used up, whatever comes first.
+// Only a result that stopped on the steps depends on nothing but the input and the seed.
+// progress is called every few steps with the step and how much of the budget (steps or time) is done.
+// Returns false, if cancel was closed before the optimization was done.
+func OptimizeTriangulation(points []sc.Vector, img *image.RGBA, iterations int, budget time.Duration, rangeX, rangeY float64, seed int64, cancel <-chan struct{}, progress func(step int, done float64)) ([]sc.Vector, bool) {
+	rd := rand.New(rand.NewSource(seed))
+
+	scale := math.Min(1, g_optimizerRasterSize/math.Max(rangeX, rangeY))
+	raster := createColorRaster(img, int(math.Max(1, rangeX*scale)), int(math.Max(1, rangeY*scale)))
+
+	if len(points) == 0 {
+		return points, true
+	}
+	const fixed = 4
+	current := append([]sc.Vector{{0, 0}, {rangeX, 0}, {0, rangeY}, {rangeX, rangeY}}, points...)
+
+	evaluate := func(p []sc.Vector, known map[[3]sc.Vector]float64) ([][3]sc.Vector, []float64) {
+		// Triangulate works on its own copy, we still need the order of our points.
+		triangles := delaunayTriangles(sc.Triangulate(append([]sc.Vector{}, p...)))
+		return triangles, triangleErrors(triangles, known, raster, rangeX, rangeY)
+	}
+
+	triangles, errors := evaluate(current, nil)
+	known := triangleErrorMap(triangles, errors)
+	currentError := sumOf(errors)
+
+	maxStep := calcExpectedRadius(len(points), rangeX, rangeY, 0) / 2.0
+	minStep := rangeX / float64(raster.width)
+
+	start := time.Now()
+	reportEvery := iterations/100 + 1
+	step := 0
+	for ; step < iterations && time.Since(start) < budget && currentError > 0; step++ {
+		done := math.Max(float64(step)/float64(iterations), float64(time.Since(start))/float64(budget))
+
+		if step%reportEvery == 0 {
+			select {
+			case <-cancel:
+				return nil, false
+			default:
+			}
+			if progress != nil {
+				progress(step, done)
+			}
+		}
+
+		temperature := 0.001 * (1.0 - done)
+
+		candidate := append([]sc.Vector{}, current...)
+		i := fixed + rd.Intn(len(current)-fixed)
+
+		if rd.Float64() < g_optimizerRelocateProbability {
+			worst := 0
+			for t := range errors {
+				if errors[t] > errors[worst] {
+					worst = t
+				}
+			}
+			// Random point inside the worst triangle.
+			a, b := rd.Float64(), rd.Float64()
+			if a+b > 1 {
+				a, b = 1-a, 1-b
+			}
+			t := triangles[worst]
+			candidate[i] = sc.Add(t[0], sc.Add(sc.Mult(sc.Sub(t[1], t[0]), a), sc.Mult(sc.Sub(t[2], t[0]), b)))
+		} else {
+			s := minStep + (maxStep-minStep)*(1.0-done)
+			candidate[i] = sc.Vector{candidate[i].X + rd.NormFloat64()*s, candidate[i].Y + rd.NormFloat64()*s}
+		}
+		// Never on or outside the border, so the corners stay the only hull points there.
+		candidate[i].X = math.Max(1, math.Min(rangeX-1, candidate[i].X))
+		candidate[i].Y = math.Max(1, math.Min(rangeY-1, candidate[i].Y))
+
+		candidateTriangles, candidateErrors := evaluate(candidate, known)
+		candidateError := sumOf(candidateErrors)
+
+		delta := (candidateError - currentError) / currentError
+		if delta < 0 || (temperature > 0 && rd.Float64() < math.Exp(-delta/temperature)) {
+			current = candidate
+			triangles = candidateTriangles
+			errors = candidateErrors
+			known = triangleErrorMap(triangles, errors)
+			currentError = candidateError
+		}
+	}
+
+	if progress != nil {
+		progress(step, 1)
+	}
+
+	return current[fixed:], true
+}
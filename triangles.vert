@@ -3,6 +3,7 @@
 layout (location = 0) in vec3 vertPos;
 layout (location = 1) in vec3 vertNormal;
 layout (location = 2) in vec2 vertUV;
+layout (location = 3) in vec4 vertColor;
 
 uniform mat4 viewProjectionMat;
 uniform mat4 modelMat;
@@ -10,28 +11,122 @@ uniform mat4 modelMat;
 uniform float expectedRadiusX;
 uniform float expectedRadiusY;
 
-uniform sampler2D imageTexture;
+// Color was already calculated on the CPU (for example a palette color).
+uniform bool useVertexColor;
+// One flat color for everything (for example the filled alpha shape).
+uniform bool useExternalColor;
+uniform vec4 color;
 
+// Color adjustments, see colorAdjustment.go.
+uniform bool adjustColors;
+uniform float saturation;
+uniform float brightness;
+uniform float contrast;
+uniform float hueShift;
+uniform float colorJitter;
+uniform uint jitterSeed;
+uniform int posterizeLevels;
+
+// Relief lighting with the face normals. Keep in sync with DirectionalLight in relief.go!
+uniform bool lightFaces;
+// Direction to the light.
+uniform vec3 lightDirection;
+uniform float ambient;
+uniform float specular;
+uniform float shininess;
+
+// Gouraud shading mixes the vertex colors in the color space instead of in sRGB. simple.frag converts them back.
+uniform bool interpolateInColorSpace;
+
+// Not premultiplied.
 out fData
 {
-    vec3 color;
+    vec4 color;
 } g_out;
 
-// Samples randomly within the radius r around pos and returns the average sample color over all 6 samples.
-vec3 sampleTextureRandom(vec2 r, vec2 pos) {
-    vec3 color = texture(imageTexture, pos + r*(2*vec2(0.36123,0.83771)-1.0)).rgb;
-    color += texture(imageTexture, pos +     r*(2*vec2(0.47154,0.44896)-1.0)).rgb;
-    color += texture(imageTexture, pos +     r*(2*vec2(0.93110,0.64977)-1.0)).rgb;
-    color += texture(imageTexture, pos +     r*(2*vec2(0.15231,0.46326)-1.0)).rgb;
-    color += texture(imageTexture, pos +     r*(2*vec2(0.83720,0.11699)-1.0)).rgb;
-    color += texture(imageTexture, pos +     r*(2*vec2(0.30478,0.06818)-1.0)).rgb;
+// Only used by glass.frag. For cells, the uv is the site, so this is the offset from the site in pixels.
+uniform vec2 windowSize;
+out vec2 siteOffset;
+out vec2 pixelPosition;
+
+#include "colorSpace.glsl"
+#include "textureSampling.glsl"
+
+// lowbias32
+uint hashUint(uint x) {
+    x ^= x >> 16;
+    x *= 0x7feb352dU;
+    x ^= x >> 15;
+    x *= 0x846ca68bU;
+    x ^= x >> 16;
+    return x;
+}
+
+float hashToSigned(uint h) {
+    return float(h & 0xffffffU) / float(0xffffffU) * 2.0 - 1.0;
+}
+
+// uv identifies the cell, so every cell gets its own jitter.
+vec3 adjustColor(vec3 c, vec2 uv) {
+    if (hueShift != 0.0) {
+        vec3 k = normalize(vec3(1));
+        c = c*cos(hueShift) + cross(k, c)*sin(hueShift) + k*dot(k, c)*(1.0 - cos(hueShift));
+    }
+
+    float luminance = dot(c, vec3(0.2126, 0.7152, 0.0722));
+    c = vec3(luminance) + (c - vec3(luminance)) * saturation;
+
+    c = (c - 0.5) * contrast + 0.5;
+    c += brightness;
+
+    if (colorJitter != 0.0) {
+        uint h = hashUint(floatBitsToUint(uv.x) ^ hashUint(floatBitsToUint(uv.y) ^ hashUint(jitterSeed)));
+        for (int i = 0; i < 3; i++) {
+            h = hashUint(h);
+            c[i] += colorJitter * hashToSigned(h);
+        }
+    }
 
-    return color/6;
+    c = clamp(c, 0.0, 1.0);
+    if (posterizeLevels >= 2) {
+        c = round(c * float(posterizeLevels-1)) / float(posterizeLevels-1);
+    }
+    return c;
 }
 
+// Relative to a flat face, so faces that are not tilted keep their color.
+vec3 lightColor(vec3 c, vec3 normal) {
+    vec3 n = normalize(normal);
+    vec3 l = normalize(lightDirection);
+    float diffuse = max(dot(n, l), 0.0) / max(l.z, 0.1);
+    // Orthographic view straight from the top.
+    vec3 h = normalize(l + vec3(0, 0, 1));
+    float highlight = specular * pow(max(dot(n, h), 0.0), shininess);
+    return clamp(c * (ambient + (1.0-ambient) * diffuse) + highlight, 0.0, 1.0);
+}
 
 void main() {
     gl_Position = viewProjectionMat * modelMat * vec4(vertPos,1);
+    siteOffset = vertPos.xy - vertUV * windowSize;
+    pixelPosition = vertPos.xy;
+
+    if (useExternalColor) {
+        g_out.color = color;
+    } else if (useVertexColor) {
+        g_out.color = vertColor;
+    } else {
+        g_out.color = sampleTextureRandom(vec2(expectedRadiusX, expectedRadiusY), vec2(vertUV.x, 1.0-vertUV.y));
+    }
+
+    if (adjustColors && !useExternalColor) {
+        g_out.color.rgb = adjustColor(g_out.color.rgb, vertUV);
+    }
+
+    if (lightFaces && !useExternalColor) {
+        g_out.color.rgb = lightColor(g_out.color.rgb, vertNormal);
+    }
 
-    g_out.color = sampleTextureRandom(vec2(expectedRadiusX, expectedRadiusY), vec2(vertUV.x, 1.0-vertUV.y));
+    if (interpolateInColorSpace && !useExternalColor) {
+        g_out.color.rgb = toColorSpace(g_out.color.rgb);
+    }
 }
@@ -0,0 +1,314 @@
+// voronoiCells
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	sc "github.com/MauriceGit/sweepcircle"
+)
+
+// One Voronoi cell as a simple polygon around its site.
+// The index of a cell in the list from extractVoronoiCells is the index of the Voronoi face (and Delaunay vertex).
+type VoronoiCell struct {
+	Site    sc.Vector
+	Polygon []sc.Vector
+}
+
+// Walks all Voronoi faces and collects their polygons. Infinite edges are extended far beyond
+// the visible area, so the outer cells should be clipped if that matters.
+func extractVoronoiCells(vo sc.Voronoi, rangeX, rangeY float64) []VoronoiCell {
+	cells := make([]VoronoiCell, 0, len(vo.Faces))
+
+	farDistance := 2.0 * (rangeX + rangeY)
+
+	emptyF := sc.HEFace{}
+
+	for _, f := range vo.Faces {
+
+		if f == emptyF {
+			break
+		}
+
+		e0 := f.EEdge
+		e1 := vo.Edges[e0].ENext
+
+		site := vo.Faces[vo.Edges[e0].FFace].ReferencePoint
+		v0 := sc.Vector{}
+
+		// For the Voronoi case
+		shouldBreak := false
+		switch {
+		// Both are empty
+		case vo.Edges[e0].VOrigin == sc.EmptyVertex && vo.Edges[vo.Edges[e0].ETwin].VOrigin == sc.EmptyVertex:
+			shouldBreak = true
+		// Only the left one is empty
+		case vo.Edges[e0].VOrigin == sc.EmptyVertex:
+			v0 = farPointOnRay(vo.Vertices[vo.Edges[vo.Edges[e0].ETwin].VOrigin].Pos, bisectorDirection(vo, e0), site, true, farDistance)
+		// Right one is empty or no one is empty!
+		default:
+			v0 = vo.Vertices[vo.Edges[e0].VOrigin].Pos
+		}
+
+		if shouldBreak {
+			break
+		}
+
+		polygon := []sc.Vector{v0}
+
+		for e1 != sc.EmptyEdge && e1 != e0 {
+			v1 := vo.Vertices[vo.Edges[e1].VOrigin].Pos
+			polygon = append(polygon, v1)
+			if vo.Edges[vo.Edges[e1].ETwin].VOrigin == sc.EmptyVertex {
+				polygon = append(polygon, farPointOnRay(v1, bisectorDirection(vo, e1), site, false, farDistance))
+			}
+			e1 = vo.Edges[e1].ENext
+		}
+
+		cells = append(cells, VoronoiCell{site, polygon})
+	}
+
+	return cells
+}
+
+// Direction of a Voronoi edge, calculated from the two sites it separates.
+// The TmpEdge of infinite edges is not reliable enough for this (it can be zero).
+func bisectorDirection(vo sc.Voronoi, e sc.EdgeIndex) sc.Vector {
+	p1 := vo.Faces[vo.Edges[e].FFace].ReferencePoint
+	p2 := vo.Faces[vo.Edges[vo.Edges[e].ETwin].FFace].ReferencePoint
+	return sc.Perpendicular(sc.Sub(p2, p1))
+}
+
+// The site must always be on the left side of its (counter clockwise) cell boundary,
+// which tells us which way an infinite edge actually goes.
+// incoming is true for the edge coming from infinity into v, false for the one leaving v to infinity.
+func farPointOnRay(v, dir, site sc.Vector, incoming bool, distance float64) sc.Vector {
+	l := sc.Length(dir)
+	if l <= sc.EPS {
+		return v
+	}
+	dir = sc.Mult(dir, distance/l)
+
+	// Edge direction as seen walking along the boundary.
+	walk := dir
+	if incoming {
+		walk = sc.Mult(dir, -1)
+	}
+	if sc.SideOfLine(v, sc.Add(v, walk), site) < 0 {
+		dir = sc.Mult(dir, -1)
+	}
+	return sc.Add(v, dir)
+}
+
+// Twice the signed area. Positive for counter clockwise polygons.
+func signedPolygonArea(poly []sc.Vector) float64 {
+	area := 0.0
+	for i := range poly {
+		a := poly[i]
+		b := poly[(i+1)%len(poly)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area
+}
+
+// Returns the polygon in counter clockwise order. The input is not modified.
+func counterClockwise(poly []sc.Vector) []sc.Vector {
+	if signedPolygonArea(poly) >= 0 {
+		return poly
+	}
+	reversed := make([]sc.Vector, len(poly))
+	for i, p := range poly {
+		reversed[len(poly)-1-i] = p
+	}
+	return reversed
+}
+
+// Sutherland-Hodgman for a single half plane. Keeps everything on the side of the line through p that n points to.
+func clipPolygonByHalfPlane(poly []sc.Vector, p, n sc.Vector) []sc.Vector {
+	if len(poly) == 0 {
+		return poly
+	}
+
+	clipped := make([]sc.Vector, 0, len(poly)+1)
+
+	prev := poly[len(poly)-1]
+	prevDist := sc.Dot(sc.Sub(prev, p), n)
+	for _, cur := range poly {
+		curDist := sc.Dot(sc.Sub(cur, p), n)
+
+		if (prevDist >= 0) != (curDist >= 0) {
+			t := prevDist / (prevDist - curDist)
+			clipped = append(clipped, sc.Add(prev, sc.Mult(sc.Sub(cur, prev), t)))
+		}
+		if curDist >= 0 {
+			clipped = append(clipped, cur)
+		}
+
+		prev = cur
+		prevDist = curDist
+	}
+
+	return clipped
+}
+
+func clipPolygonToRect(poly []sc.Vector, minX, minY, maxX, maxY float64) []sc.Vector {
+	poly = clipPolygonByHalfPlane(poly, sc.Vector{minX, 0}, sc.Vector{1, 0})
+	poly = clipPolygonByHalfPlane(poly, sc.Vector{maxX, 0}, sc.Vector{-1, 0})
+	poly = clipPolygonByHalfPlane(poly, sc.Vector{0, minY}, sc.Vector{0, 1})
+	poly = clipPolygonByHalfPlane(poly, sc.Vector{0, maxY}, sc.Vector{0, -1})
+	return poly
+}
+
+// Smallest distance from p to any (infinite) edge line of the polygon.
+// For a convex polygon containing p, this is how far the polygon can be inset before p falls out.
+func minDistanceToEdges(p sc.Vector, poly []sc.Vector) float64 {
+	minDist := math.MaxFloat64
+	for i := range poly {
+		a := poly[i]
+		dir := sc.Sub(poly[(i+1)%len(poly)], a)
+		l := sc.Length(dir)
+		if l <= sc.EPS {
+			continue
+		}
+		dist := math.Abs(a.X*dir.Y-a.Y*dir.X+p.Y*dir.X-p.X*dir.Y) / l
+		minDist = math.Min(minDist, dist)
+	}
+	return minDist
+}
+
+// Moves all edges of a convex polygon inwards by dist and returns the resulting (smaller) polygon.
+// This is a real polygon offset. Short edges can vanish completely, so the result may have less corners.
+func insetConvexPolygon(poly []sc.Vector, dist float64) []sc.Vector {
+	poly = counterClockwise(poly)
+	inset := poly
+	for i := range poly {
+		a := poly[i]
+		dir := sc.Sub(poly[(i+1)%len(poly)], a)
+		l := sc.Length(dir)
+		if l <= sc.EPS {
+			continue
+		}
+		// Counter clockwise, so the inside is to the left of every edge.
+		n := sc.Mult(sc.Perpendicular(dir), -1.0/l)
+		inset = clipPolygonByHalfPlane(inset, sc.Add(a, sc.Mult(n, dist)), n)
+	}
+	return inset
+}
+
+// Creates the mosaic tiles. Every cell is cut at the image border and then shrunk by gap.
+// variation in [0,1] randomly changes the gap per cell. Small cells never invert or disappear because
+// the gap is limited to a fraction of the distance from the site to the closest edge.
+func createMosaicTiles(cells []VoronoiCell, gap, variation float64, rangeX, rangeY float64, seed int64) []VoronoiCell {
+	r := rand.New(rand.NewSource(seed))
+	tiles := make([]VoronoiCell, len(cells))
+
+	for i, c := range cells {
+		poly := clipPolygonToRect(c.Polygon, 0, 0, rangeX, rangeY)
+
+		cellGap := gap * (1.0 + variation*(2.0*r.Float64()-1.0))
+		cellGap = math.Min(cellGap, minDistanceToEdges(c.Site, poly)*g_mosaicMaxInsetRatio)
+
+		if len(poly) >= 3 && cellGap > 0 {
+			poly = insetConvexPolygon(poly, cellGap)
+		}
+		tiles[i] = VoronoiCell{c.Site, poly}
+	}
+
+	return tiles
+}
+
+// Replaces every corner of a convex polygon by a circular arc (fillet) with the given radius.
+// The radius shrinks automatically for short edges, so neighbouring fillets never overlap.
+func roundPolygonCorners(poly []sc.Vector, radius float64, segments int) []sc.Vector {
+	if len(poly) < 3 || radius <= 0 {
+		return poly
+	}
+	poly = counterClockwise(poly)
+
+	rounded := make([]sc.Vector, 0, len(poly)*(segments+1))
+
+	for i, p := range poly {
+		prev := poly[(i+len(poly)-1)%len(poly)]
+		next := poly[(i+1)%len(poly)]
+
+		toPrev := sc.Sub(prev, p)
+		toNext := sc.Sub(next, p)
+		lPrev := sc.Length(toPrev)
+		lNext := sc.Length(toNext)
+		if lPrev <= sc.EPS || lNext <= sc.EPS {
+			continue
+		}
+		toPrev = sc.Mult(toPrev, 1.0/lPrev)
+		toNext = sc.Mult(toNext, 1.0/lNext)
+
+		halfAngle := math.Acos(math.Max(-1, math.Min(1, sc.Dot(toPrev, toNext)))) / 2.0
+		if halfAngle <= sc.EPS || halfAngle >= math.Pi/2-sc.EPS {
+			// Degenerated or straight corner. Nothing to round.
+			rounded = append(rounded, p)
+			continue
+		}
+
+		// Distance from the corner to where the arc touches the edges.
+		tangentDist := radius / math.Tan(halfAngle)
+		tangentDist = math.Min(tangentDist, math.Min(lPrev, lNext)/2.0)
+		r := tangentDist * math.Tan(halfAngle)
+
+		bisector := sc.Normalize(sc.Add(toPrev, toNext))
+		center := sc.Add(p, sc.Mult(bisector, r/math.Sin(halfAngle)))
+
+		t1 := sc.Add(p, sc.Mult(toPrev, tangentDist))
+		t2 := sc.Add(p, sc.Mult(toNext, tangentDist))
+
+		a1 := math.Atan2(t1.Y-center.Y, t1.X-center.X)
+		a2 := math.Atan2(t2.Y-center.Y, t2.X-center.X)
+		sweep := a2 - a1
+		for sweep <= -math.Pi {
+			sweep += 2 * math.Pi
+		}
+		for sweep > math.Pi {
+			sweep -= 2 * math.Pi
+		}
+
+		for s := 0; s <= segments; s++ {
+			a := a1 + sweep*float64(s)/float64(segments)
+			rounded = append(rounded, sc.Vector{center.X + r*math.Cos(a), center.Y + r*math.Sin(a)})
+		}
+	}
+
+	return rounded
+}
+
+// Chaikin's corner cutting. Every iteration replaces each edge by two points at 1/4 and 3/4.
+// Converges to a quadratic B-spline and keeps convex polygons convex.
+func chaikinSmooth(poly []sc.Vector, iterations int) []sc.Vector {
+	for it := 0; it < iterations && len(poly) >= 3; it++ {
+		smooth := make([]sc.Vector, 0, 2*len(poly))
+		for i, p := range poly {
+			q := poly[(i+1)%len(poly)]
+			smooth = append(smooth, sc.Add(sc.Mult(p, 0.75), sc.Mult(q, 0.25)))
+			smooth = append(smooth, sc.Add(sc.Mult(p, 0.25), sc.Mult(q, 0.75)))
+		}
+		poly = smooth
+	}
+	return poly
+}
+
+// Applies the selected cell shape to all cells. Returns a new list, the input cells are not modified.
+func shapeCells(cells []VoronoiCell, shape int, radius float64, iterations int) []VoronoiCell {
+	if shape == CELL_SHAPE_SHARP {
+		return cells
+	}
+
+	shaped := make([]VoronoiCell, len(cells))
+	for i, c := range cells {
+		switch shape {
+		case CELL_SHAPE_ROUNDED:
+			shaped[i] = VoronoiCell{c.Site, roundPolygonCorners(c.Polygon, radius, g_cellRoundSegments)}
+		case CELL_SHAPE_SMOOTH:
+			shaped[i] = VoronoiCell{c.Site, chaikinSmooth(c.Polygon, iterations)}
+		default:
+			shaped[i] = c
+		}
+	}
+	return shaped
+}
@@ -0,0 +1,123 @@
+#version 330
+
+// Keep these in sync with worley.go!
+#define WORLEY_F1           0
+#define WORLEY_F2           1
+#define WORLEY_F2_MINUS_F1  2
+#define WORLEY_CRACKLE      3
+#define WORLEY_SCALES       4
+#define CRACK_WIDTH         0.15
+
+// (first site, end of sites) per grid cell.
+uniform sampler2D gridTexture;
+// Site positions in window coordinates, sorted by grid cell.
+uniform sampler2D siteTexture;
+uniform int siteTextureWidth;
+uniform ivec2 gridSize;
+uniform float cellSize;
+
+uniform vec2 windowSize;
+// Window position at the corner of the viewport and pixels per window pixel. Offscreen exports render
+// parts of the window at a higher resolution.
+uniform vec2 viewOrigin;
+uniform float viewScale;
+uniform float expectedRadius;
+uniform float expectedRadiusX;
+uniform float expectedRadiusY;
+
+uniform int mode;
+uniform bool invert;
+uniform bool modulateColor;
+
+in vec2 vUV;
+out vec4 colorOut;
+
+#include "colorSpace.glsl"
+#include "textureSampling.glsl"
+
+vec2 site(int i) {
+    return texelFetch(siteTexture, ivec2(i % siteTextureWidth, i / siteTextureWidth), 0).rg;
+}
+
+float worleyValue(float f1, float f2) {
+    float v = 0.0;
+    switch (mode) {
+        case WORLEY_F1:
+            v = f1;
+            break;
+        case WORLEY_F2:
+            v = f2 / 2.0;
+            break;
+        case WORLEY_F2_MINUS_F1:
+            v = f2 - f1;
+            break;
+        case WORLEY_CRACKLE:
+            v = smoothstep(0.0, CRACK_WIDTH, f2 - f1);
+            break;
+        case WORLEY_SCALES:
+            if (f2 > 0.0) {
+                v = f1 / f2;
+            }
+            break;
+    }
+    return clamp(v, 0.0, 1.0);
+}
+
+void main() {
+    // The window has the same coordinates as our points.
+    vec2 p = viewOrigin + gl_FragCoord.xy / viewScale;
+    ivec2 c = clamp(ivec2(p / cellSize), ivec2(0), gridSize - 1);
+
+    float f1 = 1e30;
+    float f2 = 1e30;
+    vec2 nearest = vec2(0);
+
+    int maxRing = max(gridSize.x, gridSize.y);
+    for (int ring = 0; ring <= maxRing; ring++) {
+        for (int y = c.y - ring; y <= c.y + ring; y++) {
+            if (y < 0 || y >= gridSize.y) {
+                continue;
+            }
+            for (int x = c.x - ring; x <= c.x + ring; x++) {
+                if (x < 0 || x >= gridSize.x) {
+                    continue;
+                }
+                // Only the border of the ring. The inside was already visited.
+                if (y != c.y - ring && y != c.y + ring && x != c.x - ring && x != c.x + ring) {
+                    continue;
+                }
+                vec2 range = texelFetch(gridTexture, ivec2(x, y), 0).rg;
+                for (int i = int(range.x); i < int(range.y); i++) {
+                    vec2 s = site(i);
+                    float d = dot(s - p, s - p);
+                    if (d < f1) {
+                        f2 = f1;
+                        f1 = d;
+                        nearest = s;
+                    } else if (d < f2) {
+                        f2 = d;
+                    }
+                }
+            }
+        }
+        // Every cell in the next ring is at least ring*cellSize away.
+        if (f2 <= pow(float(ring) * cellSize, 2.0)) {
+            break;
+        }
+    }
+
+    float v = worleyValue(sqrt(f1) / expectedRadius, sqrt(f2) / expectedRadius);
+    if (invert) {
+        v = 1.0 - v;
+    }
+
+    vec4 color = vec4(vec3(v), 1);
+    if (modulateColor) {
+        vec2 uv = nearest / windowSize;
+        color = sampleTextureRandom(vec2(expectedRadiusX, expectedRadiusY), vec2(uv.x, 1.0-uv.y));
+        color.rgb *= v;
+    }
+
+    // Premultiplied, like everything we blend.
+    colorOut = vec4(color.rgb * color.a, color.a);
+}
@@ -0,0 +1,190 @@
+// worley
+package main
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+
+	sc "github.com/MauriceGit/sweepcircle"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Keep these in sync with worley.frag!
+const (
+	WORLEY_F1          = iota
+	WORLEY_F2          = iota
+	WORLEY_F2_MINUS_F1 = iota
+	WORLEY_CRACKLE     = iota
+	WORLEY_SCALES      = iota
+)
+
+// Width of the dark cracks in WORLEY_CRACKLE, relative to the expected point distance.
+const g_worleyCrackWidth = 0.15
+
+// Uniform grid over all sites so the nearest sites of a pixel can be found without looking at all of them.
+// The same layout is uploaded as two textures for worley.frag.
+type SiteGrid struct {
+	CellSize      float64
+	Width, Height int
+	// The sites of grid cell i are Sites[Start[i]:Start[i+1]].
+	Start []int32
+	Sites []sc.Vector
+	// Position of every site in the original point list.
+	Index []int32
+}
+
+func (g SiteGrid) cellOf(p sc.Vector) (int, int) {
+	x := int(p.X / g.CellSize)
+	y := int(p.Y / g.CellSize)
+	x = int(math.Max(0, math.Min(float64(g.Width-1), float64(x))))
+	y = int(math.Max(0, math.Min(float64(g.Height-1), float64(y))))
+	return x, y
+}
+
+// Sorts all points into grid cells of the given size (counting sort, so the order within a cell is stable).
+func CreateSiteGrid(points []sc.Vector, rangeX, rangeY, cellSize float64) SiteGrid {
+	g := SiteGrid{
+		CellSize: cellSize,
+		Width:    int(math.Ceil(rangeX/cellSize)) + 1,
+		Height:   int(math.Ceil(rangeY/cellSize)) + 1,
+	}
+	g.Start = make([]int32, g.Width*g.Height+1)
+	g.Sites = make([]sc.Vector, len(points))
+	g.Index = make([]int32, len(points))
+
+	for _, p := range points {
+		x, y := g.cellOf(p)
+		g.Start[y*g.Width+x+1]++
+	}
+	for i := 1; i < len(g.Start); i++ {
+		g.Start[i] += g.Start[i-1]
+	}
+	fill := make([]int32, g.Width*g.Height)
+	copy(fill, g.Start)
+	for i, p := range points {
+		x, y := g.cellOf(p)
+		g.Sites[fill[y*g.Width+x]] = p
+		g.Index[fill[y*g.Width+x]] = int32(i)
+		fill[y*g.Width+x]++
+	}
+
+	return g
+}
+
+// Distances to the closest and second closest site and the closest site itself.
+// The grid is searched in growing rings until no unvisited cell can contain anything closer than f2.
+func (g SiteGrid) NearestTwo(p sc.Vector) (float64, float64, sc.Vector) {
+	f1 := math.MaxFloat64
+	f2 := math.MaxFloat64
+	nearest := sc.Vector{}
+
+	cx, cy := g.cellOf(p)
+	maxRing := int(math.Max(float64(g.Width), float64(g.Height)))
+
+	for ring := 0; ring <= maxRing; ring++ {
+		for y := cy - ring; y <= cy+ring; y++ {
+			if y < 0 || y >= g.Height {
+				continue
+			}
+			for x := cx - ring; x <= cx+ring; x++ {
+				if x < 0 || x >= g.Width {
+					continue
+				}
+				// Only the border of the ring. The inside was already visited.
+				if y != cy-ring && y != cy+ring && x != cx-ring && x != cx+ring {
+					continue
+				}
+				i := y*g.Width + x
+				for _, s := range g.Sites[g.Start[i]:g.Start[i+1]] {
+					d := sc.LengthSquared(sc.Sub(s, p))
+					if d < f1 {
+						f2 = f1
+						f1 = d
+						nearest = s
+					} else if d < f2 {
+						f2 = d
+					}
+				}
+			}
+		}
+		// Every cell in the next ring is at least ring*CellSize away.
+		if f2 <= math.Pow(float64(ring)*g.CellSize, 2) {
+			break
+		}
+	}
+
+	return math.Sqrt(f1), math.Sqrt(f2), nearest
+}
+
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := math.Max(0, math.Min(1, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
+
+// Maps the two distances (already divided by the expected point distance) to a brightness in [0,1].
+func worleyValue(mode int, f1, f2 float64) float64 {
+	v := 0.0
+	switch mode {
+	case WORLEY_F1:
+		v = f1
+	case WORLEY_F2:
+		v = f2 / 2.0
+	case WORLEY_F2_MINUS_F1:
+		v = f2 - f1
+	case WORLEY_CRACKLE:
+		v = smoothstep(0, g_worleyCrackWidth, f2-f1)
+	case WORLEY_SCALES:
+		if f2 > 0 {
+			v = f1 / f2
+		}
+	}
+	return math.Max(0, math.Min(1, v))
+}
+
+// CPU version of worley.frag. Renders width x height pixels that cover [0,rangeX]x[0,rangeY],
+// so the export can be larger than the window. img and space are only needed when modulate is true.
+func RenderWorleyImage(grid SiteGrid, img *image.RGBA, mode int, invert, modulate bool, space int, expectedRadius float64, width, height int, rangeX, rangeY float64) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	sampleRadius := mgl32.Vec2{float32(expectedRadius / rangeX), float32(expectedRadius / rangeY)}
+
+	rows := make(chan int, height)
+	for y := 0; y < height; y++ {
+		rows <- y
+	}
+	close(rows)
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for py := range rows {
+				for px := 0; px < width; px++ {
+					// Image rows go down, our coordinates go up.
+					p := sc.Vector{(float64(px) + 0.5) * rangeX / float64(width), rangeY - (float64(py)+0.5)*rangeY/float64(height)}
+
+					f1, f2, site := grid.NearestTwo(p)
+					v := float32(worleyValue(mode, f1/expectedRadius, f2/expectedRadius))
+					if invert {
+						v = 1 - v
+					}
+
+					c := mgl32.Vec4{v, v, v, 1}
+					if modulate {
+						uv := mgl32.Vec2{float32(site.X / rangeX), float32(1.0 - site.Y/rangeY)}
+						c = sampleImageRandom(img, sampleRadius, uv, space)
+						c = c.Vec3().Mul(v).Vec4(c.W())
+					}
+
+					out.SetRGBA(px, py, premultipliedRGBA(c))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return out
+}